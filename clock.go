@@ -0,0 +1,27 @@
+package logrus
+
+import "time"
+
+// Clock supplies the current time and tickers to a Logger or a
+// RotatingFileWriter, so tests can control rotation, retention and entry
+// timestamps. See the clocktest package for a manually advanced Clock.
+type Clock interface {
+	Now() time.Time
+	// NewTicker behaves like time.NewTicker: it returns a channel that
+	// receives the time every d, and a function that stops the ticker.
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+// SystemClock is the wall clock, used when no Clock is configured.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
@@ -0,0 +1,95 @@
+// Package clocktest provides a Clock for tests that only moves when told to.
+package clocktest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock implements logrus.Clock. Its time changes only through Advance
+// and Set, which also fire any tickers that became due.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+type ticker struct {
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker that fires as the clock is advanced. Like
+// time.Ticker, it holds at most one pending tick and drops the rest.
+func (c *FakeClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &ticker{c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t.c, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		t.stopped = true
+	}
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to t. Moving backwards does not fire tickers.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+}
+
+// Tickers reports how many tickers are running, so a test can wait for a
+// goroutine to start its ticker before advancing the clock.
+func (c *FakeClock) Tickers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.tickers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *FakeClock) set(now time.Time) {
+	c.now = now
+	for _, t := range c.tickers {
+		if t.stopped || now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.c <- now:
+		default:
+		}
+		for !now.Before(t.next) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClockTicker(t *testing.T) {
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	ch, stop := c.NewTicker(time.Minute)
+	assert.Equal(t, 1, c.Tickers())
+
+	c.Advance(30 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	c.Advance(5 * time.Minute)
+	assert.Equal(t, start.Add(330*time.Second), <-ch)
+	select {
+	case <-ch:
+		t.Fatal("missed ticks should be dropped")
+	default:
+	}
+
+	stop()
+	assert.Equal(t, 0, c.Tickers())
+	c.Advance(time.Hour)
+	select {
+	case <-ch:
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
@@ -85,6 +85,9 @@ type Entry struct {
 	// scratch objects that get cleared and returned to the logger's entry pool
 	// after logging, so log() may reuse them in place instead of Dup'ing.
 	pooled bool
+
+	// module is the name given by Named, used to look up its level.
+	module string
 }
 
 func NewEntry(logger *Logger) *Entry {
@@ -109,6 +112,7 @@ func (entry *Entry) Dup() *Entry {
 		err:          entry.err,
 		ConsoleLevel: entry.Logger.ConsoleLevel,
 		HookLevel:    entry.Logger.HookLevel,
+		module:       entry.module,
 	}
 }
 
@@ -139,7 +143,7 @@ func (entry *Entry) WithContext(ctx context.Context) *Entry {
 	for k, v := range entry.Data {
 		dataCopy[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: entry.Time, err: entry.err, Context: ctx}
+	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: entry.Time, err: entry.err, Context: ctx, module: entry.module}
 }
 
 // Add a single field to the Entry.
@@ -173,7 +177,7 @@ func (entry *Entry) WithFields(fields Fields) *Entry {
 			data[k] = v
 		}
 	}
-	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, err: fieldErr, Context: entry.Context}
+	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, err: fieldErr, Context: entry.Context, module: entry.module}
 }
 
 // Overrides the time of the Entry.
@@ -182,7 +186,7 @@ func (entry *Entry) WithTime(t time.Time) *Entry {
 	for k, v := range entry.Data {
 		dataCopy[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: t, err: entry.err, Context: entry.Context}
+	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: t, err: entry.err, Context: entry.Context, module: entry.module}
 }
 
 // getPackageName reduces a fully qualified function name to the package name
@@ -247,19 +251,29 @@ func (entry Entry) HasCaller() (has bool) {
 }
 
 func (entry *Entry) log(level Level, msg string) {
+	entry.logFrom(level, msg, getCaller)
+}
+
+// logFrom is log with the caller looked up by caller instead of getCaller,
+// for entries whose call site is already known. caller is only called when
+// the logger reports callers.
+func (entry *Entry) logFrom(level Level, msg string, caller func() *runtime.Frame) {
 	var buffer *bytes.Buffer
 
 	// Snapshot the mutable logger config under a single lock acquisition:
 	// caller reporting flag, buffer pool, and (only when hooks can fire at this
 	// level) a shallow copy of the hooks map. Hooks are fired after the lock is
 	// released, so hook execution never blocks concurrent loggers.
+	// Note: use the logger's levels (or the entry's module level), not
+	// entry.HookLevel — Entry.WithFields does not propagate the level onto
+	// the entry it returns, and the original code (via Dup) also read the
+	// logger field directly.
+	consoleLevel, hookLevel := entry.levels()
 	entry.Logger.mu.Lock()
 	reportCaller := entry.Logger.ReportCaller
 	bufPool := entry.getBufferPool()
-	// Note: read the logger's HookLevel, not entry.HookLevel — Entry.WithFields
-	// does not propagate the level onto the entry it returns, and the original
-	// code (via Dup) also read the logger field directly.
-	hooksFire := entry.Logger.HookLevel >= level && len(entry.Logger.Hooks) > 0
+	clock := entry.Logger.Clock
+	hooksFire := hookLevel >= level && len(entry.Logger.Hooks) > 0
 	var tmpHooks LevelHooks
 	if hooksFire {
 		tmpHooks = make(LevelHooks, len(entry.Logger.Hooks))
@@ -281,14 +295,19 @@ func (entry *Entry) log(level Level, msg string) {
 	}
 
 	if newEntry.Time.IsZero() {
-		newEntry.Time = time.Now()
+		if clock != nil {
+			newEntry.Time = clock.Now()
+		} else {
+			newEntry.Time = time.Now()
+		}
 	}
 
 	newEntry.Level = level
 	newEntry.Message = msg
+	newEntry.ConsoleLevel, newEntry.HookLevel = consoleLevel, hookLevel
 
 	if reportCaller {
-		newEntry.Caller = getCaller()
+		newEntry.Caller = caller()
 	}
 	if tmpHooks != nil {
 		if err := tmpHooks.Fire(level, newEntry); err != nil {
@@ -338,7 +357,7 @@ func (entry *Entry) write() {
 }
 
 func (entry *Entry) Log(level Level, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.IsLevelEnabled(level) {
 		entry.log(level, sprintMsg(args...))
 	}
 }
@@ -346,63 +365,63 @@ func (entry *Entry) Log(level Level, args ...interface{}) {
 // Entry Printf family functions
 
 func (entry *Entry) Logf(level Level, format string, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.IsLevelEnabled(level) {
 		entry.log(level, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Trace(args ...interface{}) {
 	//entry.Log(TraceLevel, args...)
-	if entry.Logger.IsLevelEnabled(TraceLevel) {
+	if entry.IsLevelEnabled(TraceLevel) {
 		entry.log(TraceLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Debug(args ...interface{}) {
 	//entry.Log(DebugLevel, args...)
-	if entry.Logger.IsLevelEnabled(DebugLevel) {
+	if entry.IsLevelEnabled(DebugLevel) {
 		entry.log(DebugLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Print(args ...interface{}) {
 	//entry.Info(args...)
-	if entry.Logger.IsLevelEnabled(InfoLevel) {
+	if entry.IsLevelEnabled(InfoLevel) {
 		entry.log(InfoLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Info(args ...interface{}) {
 	//entry.Log(InfoLevel, args...)
-	if entry.Logger.IsLevelEnabled(InfoLevel) {
+	if entry.IsLevelEnabled(InfoLevel) {
 		entry.log(InfoLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Warn(args ...interface{}) {
 	//entry.Log(WarnLevel, args...)
-	if entry.Logger.IsLevelEnabled(WarnLevel) {
+	if entry.IsLevelEnabled(WarnLevel) {
 		entry.log(WarnLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Warning(args ...interface{}) {
 	//entry.Warn(args...)
-	if entry.Logger.IsLevelEnabled(WarnLevel) {
+	if entry.IsLevelEnabled(WarnLevel) {
 		entry.log(WarnLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Error(args ...interface{}) {
 	//entry.Log(ErrorLevel, args...)
-	if entry.Logger.IsLevelEnabled(ErrorLevel) {
+	if entry.IsLevelEnabled(ErrorLevel) {
 		entry.log(ErrorLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Fatal(args ...interface{}) {
 	//entry.Log(FatalLevel, args...)
-	if entry.Logger.IsLevelEnabled(FatalLevel) {
+	if entry.IsLevelEnabled(FatalLevel) {
 		entry.log(FatalLevel, sprintMsg(args...))
 	}
 	entry.Logger.Exit(1)
@@ -410,63 +429,63 @@ func (entry *Entry) Fatal(args ...interface{}) {
 
 func (entry *Entry) Panic(args ...interface{}) {
 	//entry.Log(PanicLevel, args...)
-	if entry.Logger.IsLevelEnabled(PanicLevel) {
+	if entry.IsLevelEnabled(PanicLevel) {
 		entry.log(PanicLevel, sprintMsg(args...))
 	}
 }
 
 func (entry *Entry) Tracef(format string, args ...interface{}) {
 	//entry.Logf(TraceLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(TraceLevel) {
+	if entry.IsLevelEnabled(TraceLevel) {
 		entry.log(TraceLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Debugf(format string, args ...interface{}) {
 	//entry.Logf(DebugLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(DebugLevel) {
+	if entry.IsLevelEnabled(DebugLevel) {
 		entry.log(DebugLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Infof(format string, args ...interface{}) {
 	//entry.Logf(InfoLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(InfoLevel) {
+	if entry.IsLevelEnabled(InfoLevel) {
 		entry.log(InfoLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Printf(format string, args ...interface{}) {
 	//entry.Infof(format, args...)
-	if entry.Logger.IsLevelEnabled(InfoLevel) {
+	if entry.IsLevelEnabled(InfoLevel) {
 		entry.log(InfoLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Warnf(format string, args ...interface{}) {
 	//entry.Logf(WarnLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(WarnLevel) {
+	if entry.IsLevelEnabled(WarnLevel) {
 		entry.log(WarnLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Warningf(format string, args ...interface{}) {
 	//entry.Warnf(format, args...)
-	if entry.Logger.IsLevelEnabled(WarnLevel) {
+	if entry.IsLevelEnabled(WarnLevel) {
 		entry.log(WarnLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Errorf(format string, args ...interface{}) {
 	//entry.Logf(ErrorLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(ErrorLevel) {
+	if entry.IsLevelEnabled(ErrorLevel) {
 		entry.log(ErrorLevel, fmt.Sprintf(format, args...))
 	}
 }
 
 func (entry *Entry) Fatalf(format string, args ...interface{}) {
 	//entry.Logf(FatalLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(FatalLevel) {
+	if entry.IsLevelEnabled(FatalLevel) {
 		entry.log(FatalLevel, fmt.Sprintf(format, args...))
 	}
 	entry.Logger.Exit(1)
@@ -474,7 +493,7 @@ func (entry *Entry) Fatalf(format string, args ...interface{}) {
 
 func (entry *Entry) Panicf(format string, args ...interface{}) {
 	//entry.Logf(PanicLevel, format, args...)
-	if entry.Logger.IsLevelEnabled(PanicLevel) {
+	if entry.IsLevelEnabled(PanicLevel) {
 		entry.log(PanicLevel, fmt.Sprintf(format, args...))
 	}
 }
@@ -482,21 +501,21 @@ func (entry *Entry) Panicf(format string, args ...interface{}) {
 // Entry Println family functions
 
 func (entry *Entry) Logln(level Level, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
+	if entry.IsLevelEnabled(level) {
 		entry.log(level, entry.sprintlnn(args...))
 	}
 }
 
 func (entry *Entry) Traceln(args ...interface{}) {
 	//entry.Logln(TraceLevel, args...)
-	if entry.Logger.IsLevelEnabled(TraceLevel) {
+	if entry.IsLevelEnabled(TraceLevel) {
 		entry.log(TraceLevel, entry.sprintlnn(args...))
 	}
 }
 
 func (entry *Entry) Debugln(args ...interface{}) {
 	//entry.Logln(DebugLevel, args...)
-	if entry.Logger.IsLevelEnabled(DebugLevel) {
+	if entry.IsLevelEnabled(DebugLevel) {
 		entry.log(DebugLevel, entry.sprintlnn(args...))
 	}
 }
@@ -507,35 +526,35 @@ func (entry *Entry) Infoln(args ...interface{}) {
 
 func (entry *Entry) Println(args ...interface{}) {
 	//entry.Infoln(args...)
-	if entry.Logger.IsLevelEnabled(InfoLevel) {
+	if entry.IsLevelEnabled(InfoLevel) {
 		entry.log(InfoLevel, entry.sprintlnn(args...))
 	}
 }
 
 func (entry *Entry) Warnln(args ...interface{}) {
 	//entry.Logln(WarnLevel, args...)
-	if entry.Logger.IsLevelEnabled(WarnLevel) {
+	if entry.IsLevelEnabled(WarnLevel) {
 		entry.log(WarnLevel, entry.sprintlnn(args...))
 	}
 }
 
 func (entry *Entry) Warningln(args ...interface{}) {
 	//entry.Warnln(args...)
-	if entry.Logger.IsLevelEnabled(WarnLevel) {
+	if entry.IsLevelEnabled(WarnLevel) {
 		entry.log(WarnLevel, entry.sprintlnn(args...))
 	}
 }
 
 func (entry *Entry) Errorln(args ...interface{}) {
 	//entry.Logln(ErrorLevel, args...)
-	if entry.Logger.IsLevelEnabled(ErrorLevel) {
+	if entry.IsLevelEnabled(ErrorLevel) {
 		entry.log(ErrorLevel, entry.sprintlnn(args...))
 	}
 }
 
 func (entry *Entry) Fatalln(args ...interface{}) {
 	//entry.Logln(FatalLevel, args...)
-	if entry.Logger.IsLevelEnabled(FatalLevel) {
+	if entry.IsLevelEnabled(FatalLevel) {
 		entry.log(FatalLevel, entry.sprintlnn(args...))
 	}
 	entry.Logger.Exit(1)
@@ -543,7 +562,7 @@ func (entry *Entry) Fatalln(args ...interface{}) {
 
 func (entry *Entry) Panicln(args ...interface{}) {
 	//entry.Logln(PanicLevel, args...)
-	if entry.Logger.IsLevelEnabled(PanicLevel) {
+	if entry.IsLevelEnabled(PanicLevel) {
 		entry.log(PanicLevel, entry.sprintlnn(args...))
 	}
 }
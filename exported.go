@@ -41,6 +41,16 @@ func GetLevel() Level {
 	return std.GetLevel()
 }
 
+// Named returns an entry for the component name on the standard logger.
+func Named(name string) *Entry {
+	return std.Named(name)
+}
+
+// SetModuleLevels replaces the module levels of the standard logger.
+func SetModuleLevels(spec string) error {
+	return std.SetModuleLevels(spec)
+}
+
 func SetMaxAge(duration time.Duration) {
 	std.SetMaxAge(duration)
 }
@@ -0,0 +1,231 @@
+package logrus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AsyncPolicy decides what an asynchronous LfsHook does when its queue is
+// full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock makes Fire wait for room in the queue.
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropNewest discards the entry being fired.
+	AsyncDropNewest
+	// AsyncDropLowestLevel discards the most verbose entry among the queued
+	// ones and the one being fired, so errors survive a flood of debug logs.
+	AsyncDropLowestLevel
+)
+
+// asyncExitTimeout bounds how long the exit handler waits for queues to
+// drain before Fatal exits.
+const asyncExitTimeout = 5 * time.Second
+
+// AsyncStats counts the entries an asynchronous LfsHook has handled.
+type AsyncStats struct {
+	// Queued is the number of entries waiting to be written.
+	Queued  int
+	Written uint64
+	Dropped uint64
+}
+
+var (
+	asyncHooksMu   sync.Mutex
+	asyncHooks     = map[*LfsHook]struct{}{}
+	asyncHooksOnce sync.Once
+)
+
+// lfsQueue hands entries from Fire to the goroutine writing them.
+type lfsQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	entries []*Entry
+	size    int
+	policy  AsyncPolicy
+	// busy is set while the writer goroutine writes an entry it took off
+	// the queue.
+	busy    bool
+	closed  bool
+	done    chan struct{}
+	written uint64
+	dropped uint64
+}
+
+// EnableAsync makes Fire queue entries, up to queueSize of them, for a
+// goroutine that formats and writes them, so a slow disk does not stall the
+// caller. policy decides what happens when the queue is full. Fatal and
+// Panic entries are still written synchronously, after the queue, as the
+// process may end right after them; pending entries are also flushed by an
+// exit handler before Fatal exits. Call it before adding the hook to a
+// logger, and Close the hook to stop the goroutine.
+func (hook *LfsHook) EnableAsync(queueSize int, policy AsyncPolicy) {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	q := &lfsQueue{size: queueSize, policy: policy, done: make(chan struct{})}
+	q.cond = sync.NewCond(&q.mu)
+	if !hook.async.CompareAndSwap(nil, q) {
+		return
+	}
+	asyncHooksOnce.Do(func() {
+		// Queues drain into the writers' buffers, so run before those
+		// are flushed.
+		DeferExitHandler(flushAsyncHooks)
+	})
+	asyncHooksMu.Lock()
+	asyncHooks[hook] = struct{}{}
+	asyncHooksMu.Unlock()
+	go q.run(hook)
+}
+
+func flushAsyncHooks() {
+	asyncHooksMu.Lock()
+	hooks := make([]*LfsHook, 0, len(asyncHooks))
+	for hook := range asyncHooks {
+		hooks = append(hooks, hook)
+	}
+	asyncHooksMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), asyncExitTimeout)
+	defer cancel()
+	for _, hook := range hooks {
+		if err := hook.Flush(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to flush log hook: %v\n", err)
+		}
+	}
+}
+
+// Flush waits until every entry queued so far has been written, or ctx is
+// done. It returns at once for a synchronous hook.
+func (hook *LfsHook) Flush(ctx context.Context) error {
+	q := hook.async.Load()
+	if q == nil {
+		return nil
+	}
+	return q.flush(ctx)
+}
+
+// Close drains the queue of an asynchronous hook and stops its goroutine;
+// later entries are written synchronously. It does not close the writers.
+func (hook *LfsHook) Close() error {
+	q := hook.async.Load()
+	if q == nil {
+		return nil
+	}
+	asyncHooksMu.Lock()
+	delete(asyncHooks, hook)
+	asyncHooksMu.Unlock()
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	<-q.done
+	return nil
+}
+
+// AsyncStats reports the queue counters; it is zero for a synchronous hook.
+func (hook *LfsHook) AsyncStats() AsyncStats {
+	q := hook.async.Load()
+	if q == nil {
+		return AsyncStats{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return AsyncStats{Queued: len(q.entries), Written: q.written, Dropped: q.dropped}
+}
+
+// fire queues entry. It reports false if the caller must write the entry
+// itself: the queue is closed, or the entry is Fatal or Panic.
+func (q *lfsQueue) fire(hook *LfsHook, entry *Entry) (bool, error) {
+	if entry.Level <= FatalLevel {
+		_ = q.flush(context.Background())
+		return false, nil
+	}
+	// log() reuses the entry once hooks return.
+	e := entry.Dup()
+	e.Level = entry.Level
+	e.Message = entry.Message
+	e.Caller = entry.Caller
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.entries) >= q.size && !q.closed {
+		switch q.policy {
+		case AsyncDropNewest:
+			q.dropped++
+			return true, nil
+		case AsyncDropLowestLevel:
+			lowest := 0
+			for i, queued := range q.entries {
+				if queued.Level > q.entries[lowest].Level {
+					lowest = i
+				}
+			}
+			q.dropped++
+			if e.Level >= q.entries[lowest].Level {
+				return true, nil
+			}
+			q.entries = append(q.entries[:lowest], q.entries[lowest+1:]...)
+		default:
+			q.cond.Wait()
+		}
+	}
+	if q.closed {
+		return false, nil
+	}
+	q.entries = append(q.entries, e)
+	q.cond.Broadcast()
+	return true, nil
+}
+
+func (q *lfsQueue) flush(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	defer stop()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.entries) > 0 || q.busy {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q.cond.Wait()
+	}
+	return nil
+}
+
+// run writes queued entries until the queue is closed and drained.
+func (q *lfsQueue) run(hook *LfsHook) {
+	defer close(q.done)
+	q.mu.Lock()
+	for {
+		for len(q.entries) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.entries) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		entry := q.entries[0]
+		q.entries[0] = nil
+		q.entries = q.entries[1:]
+		q.busy = true
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		if err := hook.write(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to fire hook: %v\n", err)
+		}
+
+		q.mu.Lock()
+		q.busy = false
+		q.written++
+		q.cond.Broadcast()
+	}
+}
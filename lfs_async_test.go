@@ -0,0 +1,177 @@
+package logrus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedWriter blocks writes until its gate is opened.
+type gatedWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	gate    chan struct{}
+	started chan struct{}
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{gate: make(chan struct{}), started: make(chan struct{}, 100)}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func newAsyncTestHook(w io.Writer, size int, policy AsyncPolicy) (*LfsHook, *Logger) {
+	hook := newLocalFileSystemHook(WriterMap{
+		FatalLevel: w,
+		ErrorLevel: w,
+		InfoLevel:  w,
+		DebugLevel: w,
+	}, &TextFormatter{DisableTimestamp: true})
+	hook.EnableAsync(size, policy)
+	logger := New()
+	logger.Out = io.Discard
+	logger.SetLevel(DebugLevel)
+	logger.AddHook(hook)
+	return hook, logger
+}
+
+func TestLfsHookAsyncFlushAndClose(t *testing.T) {
+	w := newGatedWriter()
+	close(w.gate)
+	hook, logger := newAsyncTestHook(w, 16, AsyncBlock)
+
+	logger.WithField("n", 1).Info("first")
+	logger.Info("second")
+	require.NoError(t, hook.Flush(context.Background()))
+	assert.Contains(t, w.String(), "msg=first")
+	assert.Contains(t, w.String(), "n=1")
+	assert.Contains(t, w.String(), "msg=second")
+	assert.Equal(t, AsyncStats{Written: 2}, hook.AsyncStats())
+
+	logger.Info("third")
+	require.NoError(t, hook.Close())
+	assert.Contains(t, w.String(), "msg=third")
+	// After Close entries are written synchronously.
+	logger.Info("fourth")
+	assert.Contains(t, w.String(), "msg=fourth")
+}
+
+func TestLfsHookAsyncDropNewest(t *testing.T) {
+	w := newGatedWriter()
+	hook, logger := newAsyncTestHook(w, 2, AsyncDropNewest)
+	defer hook.Close()
+
+	logger.Info("taken")
+	<-w.started
+	logger.Info("queued 1")
+	logger.Info("queued 2")
+	logger.Error("dropped")
+	assert.Equal(t, AsyncStats{Queued: 2, Dropped: 1}, hook.AsyncStats())
+
+	close(w.gate)
+	require.NoError(t, hook.Flush(context.Background()))
+	assert.Contains(t, w.String(), "queued 2")
+	assert.NotContains(t, w.String(), "dropped")
+}
+
+func TestLfsHookAsyncDropLowestLevel(t *testing.T) {
+	w := newGatedWriter()
+	hook, logger := newAsyncTestHook(w, 2, AsyncDropLowestLevel)
+	defer hook.Close()
+
+	logger.Info("taken")
+	<-w.started
+	logger.Debug("verbose")
+	logger.Info("kept")
+	logger.Error("important")
+	logger.Debug("also verbose")
+	assert.Equal(t, AsyncStats{Queued: 2, Dropped: 2}, hook.AsyncStats())
+
+	close(w.gate)
+	require.NoError(t, hook.Flush(context.Background()))
+	assert.Contains(t, w.String(), "msg=kept")
+	assert.Contains(t, w.String(), "msg=important")
+	assert.NotContains(t, w.String(), "verbose")
+}
+
+func TestLfsHookAsyncBlock(t *testing.T) {
+	w := newGatedWriter()
+	hook, logger := newAsyncTestHook(w, 1, AsyncBlock)
+	defer hook.Close()
+
+	logger.Info("taken")
+	<-w.started
+	logger.Info("queued")
+	fired := make(chan struct{})
+	go func() {
+		logger.Info("waiting")
+		close(fired)
+	}()
+	select {
+	case <-fired:
+		t.Fatal("Fire did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, hook.Flush(ctx), context.DeadlineExceeded)
+
+	close(w.gate)
+	<-fired
+	require.NoError(t, hook.Flush(context.Background()))
+	assert.Contains(t, w.String(), "msg=waiting")
+	assert.Equal(t, uint64(0), hook.AsyncStats().Dropped)
+}
+
+func TestLfsHookAsyncFatalIsSynchronous(t *testing.T) {
+	var buf bytes.Buffer
+	hook := newLocalFileSystemHook(WriterMap{ErrorLevel: &buf, FatalLevel: &buf}, &TextFormatter{DisableTimestamp: true})
+	hook.EnableAsync(4, AsyncBlock)
+	defer hook.Close()
+
+	require.NoError(t, hook.Fire(&Entry{Logger: New(), Level: ErrorLevel, Message: "before", Data: Fields{}}))
+	require.NoError(t, hook.Fire(&Entry{Logger: New(), Level: FatalLevel, Message: "fatal", Data: Fields{}}))
+	// Both are on disk when Fire returns, in order.
+	assert.Regexp(t, "(?s)msg=before.*msg=fatal", buf.String())
+}
+
+func TestLfsHookAsyncExitHandlerRunsBeforeBufferFlush(t *testing.T) {
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: t.TempDir(), BaseName: "app", BufferSize: 1024})
+	require.NoError(t, err)
+	defer w.Close()
+	hook, _ := newAsyncTestHook(w, 4, AsyncBlock)
+	defer hook.Close()
+
+	index := func(fn func()) int {
+		for i, h := range handlers {
+			if reflect.ValueOf(h).Pointer() == reflect.ValueOf(fn).Pointer() {
+				return i
+			}
+		}
+		return -1
+	}
+	async, buffers := index(flushAsyncHooks), index(flushBufferedWriters)
+	require.NotEqual(t, -1, async)
+	require.NotEqual(t, -1, buffers)
+	assert.Less(t, async, buffers, "queues must drain into the buffers before they are flushed")
+}
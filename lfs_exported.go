@@ -1,65 +1,264 @@
 package logrus
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
-func createRotatingWriter(level, logPath string) (*RotatingFileWriter, error) {
-	prefix := ""
-	if len(level) > 0 {
-		prefix = "." + level
+var (
+	// lfsWriters holds the writers created by NewLocalFileSystemHook so
+	// they can be rotated or reopened together, e.g. from a signal handler.
+	lfsWritersMu sync.Mutex
+	lfsWriters   []*RotatingFileWriter
+)
+
+func registerLfsWriters(writers ...*RotatingFileWriter) {
+	lfsWritersMu.Lock()
+	defer lfsWritersMu.Unlock()
+	lfsWriters = append(lfsWriters, writers...)
+}
+
+func unregisterLfsWriters(writers ...*RotatingFileWriter) {
+	lfsWritersMu.Lock()
+	defer lfsWritersMu.Unlock()
+	kept := lfsWriters[:0]
+	for _, w := range lfsWriters {
+		remove := false
+		for _, closed := range writers {
+			remove = remove || w == closed
+		}
+		if !remove {
+			kept = append(kept, w)
+		}
 	}
-	dir := filepath.Dir(logPath)
-	baseName := filepath.Base(logPath) + prefix
-	linkName := filepath.Base(logPath) + prefix + ".log"
-	return NewRotatingFileWriter(RotatingFileConfig{
-		Dir:      dir,
-		BaseName: baseName,
-		Ext:      ".log",
-		Rotation: time.Hour,
-		MaxAge:   GetMaxAge(),
-		LinkName: linkName,
+	lfsWriters = kept
+}
+
+func eachLfsWriter(fn func(*RotatingFileWriter) error) error {
+	lfsWritersMu.Lock()
+	writers := append([]*RotatingFileWriter(nil), lfsWriters...)
+	lfsWritersMu.Unlock()
+	var errs []error
+	for _, w := range writers {
+		if err := fn(w); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RotateLocalFileSystemLogs starts a new file on every writer created by
+// NewLocalFileSystemHook or ConfigLocalFileSystemLogger.
+func RotateLocalFileSystemLogs() error {
+	return eachLfsWriter((*RotatingFileWriter).Rotate)
+}
+
+// ReopenLocalFileSystemLogs reopens the current file of every writer created
+// by NewLocalFileSystemHook or ConfigLocalFileSystemLogger, for use after an
+// external logrotate run.
+func ReopenLocalFileSystemLogs() error {
+	return eachLfsWriter((*RotatingFileWriter).Reopen)
+}
+
+// LfsOptions configures NewLocalFileSystemHook.
+type LfsOptions struct {
+	// Dir and BaseName name the files. Every level in Levels goes to the
+	// common file Dir/BaseName.<stamp>.log, and each level in SplitLevels
+	// also to Dir/BaseName.<level>.<stamp>.log. Links named BaseName.log and
+	// BaseName.<level>.log follow the current files.
+	Dir      string
+	BaseName string
+	// Levels are the levels the hook handles; nil means Debug through Panic.
+	Levels []Level
+	// SplitLevels get a file of their own; nil means Debug, Info, Warn and
+	// Error, and an empty slice leaves only the common file. Fatal and Panic
+	// entries go to the Error file unless they are split themselves.
+	SplitLevels []Level
+	// Rotation is the period of each file, an hour by default. MaxSize,
+	// MaxBackups and MaxTotalSize apply to each file set as in
+	// RotatingFileConfig. MaxAge defaults to the target logger's MaxAge.
+	Rotation     time.Duration
+	MaxSize      int64
+	MaxAge       time.Duration
+	MaxBackups   int
+	MaxTotalSize int64
+	// Formatter formats entries; nil means SimpleFormatter. FileFormatters
+	// overrides it for the file of a split level, e.g. JSON for the error
+	// file, while the common file keeps Formatter.
+	Formatter      Formatter
+	FileFormatters map[Level]Formatter
+	// DefaultWriter, when set, receives entries of the levels not in
+	// Levels, which the hook then handles too.
+	DefaultWriter io.Writer
+	// Logger receives the hook; nil means the standard logger.
+	Logger *Logger
+	// AsyncQueueSize, when positive, makes the hook asynchronous with a
+	// queue of that many entries; see LfsHook.EnableAsync.
+	AsyncQueueSize int
+	AsyncPolicy    AsyncPolicy
+}
+
+// lfsCloser closes the writers of a hook made by NewLocalFileSystemHook or
+// NewPathMapHook and detaches the hook from its logger, if any.
+type lfsCloser struct {
+	once    sync.Once
+	logger  *Logger
+	hook    *LfsHook
+	writers []*RotatingFileWriter
+	err     error
+}
+
+func (c *lfsCloser) Close() error {
+	c.once.Do(func() {
+		if c.logger != nil {
+			c.logger.removeHook(c.hook)
+		}
+		if c.hook != nil {
+			// Drain queued entries while the files are still open.
+			_ = c.hook.Close()
+		}
+		unregisterLfsWriters(c.writers...)
+		var errs []error
+		for _, w := range c.writers {
+			if err := w.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		c.err = errors.Join(errs...)
 	})
+	return c.err
 }
 
-func ConfigLocalFileSystemLogger(logPath, logFileName string) {
-	baseLogPath := filepath.Join(logPath, logFileName)
-	debugWriter, err := createRotatingWriter("debug", baseLogPath)
-	if err != nil {
-		Errorf("config local file system logger error: %v", fmt.Errorf("create debug writer: %w", err))
-		return
+// NewLocalFileSystemHook creates rotating log files as described by opts and
+// adds a hook writing to them to the target logger. The returned Closer
+// removes the hook and closes the files; until then the files can be rotated
+// or reopened with RotateLocalFileSystemLogs and ReopenLocalFileSystemLogs.
+// If any file cannot be created, the ones already created are closed.
+func NewLocalFileSystemHook(opts LfsOptions) (*LfsHook, io.Closer, error) {
+	if opts.Logger == nil {
+		opts.Logger = std
 	}
-	infoWriter, err := createRotatingWriter("info", baseLogPath)
-	if err != nil {
-		Errorf("config local file system logger error: %v", fmt.Errorf("create info writer: %w", err))
-		return
+	if opts.Levels == nil {
+		opts.Levels = []Level{PanicLevel, FatalLevel, ErrorLevel, WarnLevel, InfoLevel, DebugLevel}
 	}
-	warnWriter, err := createRotatingWriter("warn", baseLogPath)
-	if err != nil {
-		Errorf("config local file system logger error: %v", fmt.Errorf("create warn writer: %w", err))
-		return
+	if opts.SplitLevels == nil {
+		opts.SplitLevels = []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel}
 	}
-	errorWriter, err := createRotatingWriter("error", baseLogPath)
-	if err != nil {
-		Errorf("config local file system logger error: %v", fmt.Errorf("create error writer: %w", err))
-		return
+	if opts.Rotation <= 0 {
+		opts.Rotation = time.Hour
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = opts.Logger.GetMaxAge()
+	}
+	if opts.Formatter == nil {
+		opts.Formatter = &SimpleFormatter{}
 	}
-	commonWriter, err := createRotatingWriter("", baseLogPath)
+
+	closer := &lfsCloser{logger: opts.Logger}
+	fail := func(err error) (*LfsHook, io.Closer, error) {
+		for _, w := range closer.writers {
+			w.Close()
+		}
+		return nil, nil, err
+	}
+	common, err := createRotatingWriter("", opts)
 	if err != nil {
-		Errorf("config local file system logger error: %v", fmt.Errorf("create common writer: %w", err))
-		return
-	}
-	multiErrorWriter := io.MultiWriter(errorWriter, commonWriter)
-	lfHook := newLocalFileSystemHook(WriterMap{
-		DebugLevel: io.MultiWriter(debugWriter, commonWriter),
-		InfoLevel:  io.MultiWriter(infoWriter, commonWriter),
-		WarnLevel:  io.MultiWriter(warnWriter, commonWriter),
-		ErrorLevel: multiErrorWriter,
-		FatalLevel: multiErrorWriter,
-		PanicLevel: multiErrorWriter,
-	}, &SimpleFormatter{})
-	AddHook(lfHook)
+		return fail(fmt.Errorf("create common writer: %w", err))
+	}
+	closer.writers = append(closer.writers, common)
+	split := make(map[Level]*RotatingFileWriter)
+	for _, level := range opts.SplitLevels {
+		if _, ok := split[level]; ok {
+			continue
+		}
+		w, err := createRotatingWriter(lfsLevelName(level), opts)
+		if err != nil {
+			return fail(fmt.Errorf("create %s writer: %w", level, err))
+		}
+		closer.writers = append(closer.writers, w)
+		split[level] = w
+	}
+
+	writers := make(map[Level][]io.Writer, len(opts.Levels))
+	for _, level := range opts.Levels {
+		own, ok := split[level]
+		if !ok && (level == FatalLevel || level == PanicLevel) {
+			own, ok = split[ErrorLevel]
+		}
+		if ok {
+			writers[level] = []io.Writer{own, common}
+		} else {
+			writers[level] = []io.Writer{common}
+		}
+	}
+	registerLfsWriters(closer.writers...)
+	closer.hook = newRoutedHook(writers, opts.Formatter)
+	for level, formatter := range opts.FileFormatters {
+		if w, ok := split[level]; ok {
+			closer.hook.SetWriterFormatter(w, formatter)
+		}
+	}
+	if opts.DefaultWriter != nil {
+		closer.hook.SetDefaultWriter(opts.DefaultWriter)
+	}
+	if opts.AsyncQueueSize > 0 {
+		closer.hook.EnableAsync(opts.AsyncQueueSize, opts.AsyncPolicy)
+	}
+	opts.Logger.AddHook(closer.hook)
+	return closer.hook, closer, nil
+}
+
+// lfsLevelName names the file of a split level; Warn files have always been
+// called "warn".
+func lfsLevelName(level Level) string {
+	if level == WarnLevel {
+		return "warn"
+	}
+	return level.String()
+}
+
+func createRotatingWriter(level string, opts LfsOptions) (*RotatingFileWriter, error) {
+	baseName := opts.BaseName
+	if level != "" {
+		baseName += "." + level
+	}
+	return NewRotatingFileWriter(RotatingFileConfig{
+		Dir:          opts.Dir,
+		BaseName:     baseName,
+		Ext:          ".log",
+		Rotation:     opts.Rotation,
+		MaxAge:       opts.MaxAge,
+		MaxSize:      opts.MaxSize,
+		MaxBackups:   opts.MaxBackups,
+		MaxTotalSize: opts.MaxTotalSize,
+		LinkName:     baseName + ".log",
+	})
+}
+
+// ConfigLocalFileSystem adds hourly rotating files under logPath to the
+// logger, one per level from Debug to Error plus a common one, kept for the
+// logger's MaxAge. Close the returned Closer to detach and close them; use
+// NewLocalFileSystemHook for more control.
+func (logger *Logger) ConfigLocalFileSystem(logPath, logFileName string) (io.Closer, error) {
+	baseLogPath := filepath.Join(logPath, logFileName)
+	_, closer, err := NewLocalFileSystemHook(LfsOptions{
+		Dir:      filepath.Dir(baseLogPath),
+		BaseName: filepath.Base(baseLogPath),
+		Logger:   logger,
+	})
+	return closer, err
+}
+
+// ConfigLocalFileSystemLogger calls ConfigLocalFileSystem on the standard
+// logger. Errors are logged; use NewLocalFileSystemHook to handle them
+// instead.
+func ConfigLocalFileSystemLogger(logPath, logFileName string) {
+	if _, err := std.ConfigLocalFileSystem(logPath, logFileName); err != nil {
+		Errorf("config local file system logger error: %v", err)
+	}
 }
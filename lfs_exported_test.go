@@ -0,0 +1,148 @@
+package logrus
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLocalFileSystemHook(t *testing.T) {
+	dir := t.TempDir()
+	logger := New()
+	logger.Out = io.Discard
+	hook, closer, err := NewLocalFileSystemHook(LfsOptions{
+		Dir:         dir,
+		BaseName:    "app",
+		SplitLevels: []Level{ErrorLevel},
+		Formatter:   &TextFormatter{DisableTimestamp: true},
+		Logger:      logger,
+	})
+	require.NoError(t, err)
+	writers := closer.(*lfsCloser).writers
+	require.Len(t, writers, 2)
+	assert.Contains(t, logger.Hooks[InfoLevel], Hook(hook))
+
+	logger.Info("to common")
+	logger.Error("to both")
+	require.NoError(t, closer.Close())
+
+	read := func(w *RotatingFileWriter) string {
+		b, err := os.ReadFile(w.path)
+		require.NoError(t, err)
+		return string(b)
+	}
+	common, errorFile := read(writers[0]), read(writers[1])
+	assert.Contains(t, common, "to common")
+	assert.Contains(t, common, "to both")
+	assert.NotContains(t, errorFile, "to common")
+	assert.Contains(t, errorFile, "to both")
+	assert.True(t, strings.HasPrefix(filepath.Base(writers[1].path), "app.error."))
+
+	// Closing detaches the hook and the writers.
+	assert.Empty(t, logger.Hooks[InfoLevel])
+	lfsWritersMu.Lock()
+	for _, w := range lfsWriters {
+		assert.NotContains(t, writers, w)
+	}
+	lfsWritersMu.Unlock()
+	require.NoError(t, closer.Close())
+}
+
+func TestNewLocalFileSystemHookDefaults(t *testing.T) {
+	dir := t.TempDir()
+	logger := New()
+	logger.Out = io.Discard
+	_, closer, err := NewLocalFileSystemHook(LfsOptions{Dir: dir, BaseName: "app", Logger: logger})
+	require.NoError(t, err)
+	defer closer.Close()
+
+	var names []string
+	for _, w := range closer.(*lfsCloser).writers {
+		names = append(names, w.baseName)
+		assert.Equal(t, logger.MaxAge, w.maxAge)
+	}
+	assert.Equal(t, []string{"app", "app.debug", "app.info", "app.warn", "app.error"}, names)
+	assert.Empty(t, logger.Hooks[TraceLevel])
+}
+
+func TestNewLocalFileSystemHookDefaultWriter(t *testing.T) {
+	var trace bytes.Buffer
+	logger := New()
+	logger.Out = io.Discard
+	logger.SetLevel(TraceLevel)
+	_, closer, err := NewLocalFileSystemHook(LfsOptions{
+		Dir:           t.TempDir(),
+		BaseName:      "app",
+		DefaultWriter: &trace,
+		Logger:        logger,
+	})
+	require.NoError(t, err)
+	defer closer.Close()
+
+	logger.Trace("traced")
+	assert.Contains(t, trace.String(), "traced")
+}
+
+func TestNewLocalFileSystemHookError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	logger := New()
+	_, closer, err := NewLocalFileSystemHook(LfsOptions{Dir: filepath.Join(file, "logs"), BaseName: "app", Logger: logger})
+	assert.Error(t, err)
+	assert.Nil(t, closer)
+	assert.Empty(t, logger.Hooks)
+}
+
+func TestNewLocalFileSystemHookFileFormatters(t *testing.T) {
+	logger := New()
+	logger.Out = io.Discard
+	_, closer, err := NewLocalFileSystemHook(LfsOptions{
+		Dir:            t.TempDir(),
+		BaseName:       "app",
+		SplitLevels:    []Level{ErrorLevel},
+		Formatter:      &TextFormatter{DisableTimestamp: true},
+		FileFormatters: map[Level]Formatter{ErrorLevel: &JSONFormatter{}},
+		Logger:         logger,
+	})
+	require.NoError(t, err)
+	writers := closer.(*lfsCloser).writers
+	logger.Error("failed")
+	require.NoError(t, closer.Close())
+
+	common, err := os.ReadFile(writers[0].path)
+	require.NoError(t, err)
+	errorFile, err := os.ReadFile(writers[1].path)
+	require.NoError(t, err)
+	assert.Contains(t, string(common), "msg=failed")
+	assert.Contains(t, string(errorFile), `"msg":"failed"`)
+}
+
+func TestLoggerConfigLocalFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	loggers := map[string]*Logger{"access": New(), "app": New()}
+	loggers["access"].SetMaxAge(3 * time.Hour)
+	for name, logger := range loggers {
+		logger.Out = io.Discard
+		closer, err := logger.ConfigLocalFileSystem(filepath.Join(dir, name), name)
+		require.NoError(t, err)
+		defer closer.Close()
+		for _, w := range closer.(*lfsCloser).writers {
+			assert.Equal(t, filepath.Join(dir, name), w.dir)
+			assert.Equal(t, logger.MaxAge, w.maxAge)
+		}
+		logger.Info("for " + name)
+	}
+	assert.NotEmpty(t, loggers["app"].Hooks[InfoLevel])
+	assert.Empty(t, std.Hooks[InfoLevel], "the standard logger is left alone")
+
+	info := readString(t, filepath.Join(dir, "access", "access.info.log"))
+	assert.Contains(t, info, "for access")
+	assert.NotContains(t, info, "for app")
+}
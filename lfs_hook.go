@@ -1,48 +1,189 @@
 package logrus
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 var defaultFormatter = &TextFormatter{DisableColors: true}
 
 type LfsHook struct {
-	writers   WriterMap
-	levels    []Level
+	// writers lists every writer of a level; an entry is written to each.
+	writers   map[Level][]io.Writer
 	lock      *sync.Mutex
 	formatter Formatter
+	// levelFormatters and writerFormatters override formatter; a writer's
+	// formatter wins over its level's.
+	levelFormatters  map[Level]Formatter
+	writerFormatters []writerFormatter
+
+	// minLevel and maxLevel bound the levels Fire writes, see SetLevelRange.
+	minLevel uint32
+	maxLevel uint32
 
 	defaultWriter    io.Writer
 	hasDefaultWriter bool
+
+	// async is set by EnableAsync.
+	async atomic.Pointer[lfsQueue]
+}
+
+type writerFormatter struct {
+	writer    io.Writer
+	formatter Formatter
 }
 
 func newLocalFileSystemHook(output WriterMap, formatter Formatter) *LfsHook {
+	writers := make(map[Level][]io.Writer, len(output))
+	for level, w := range output {
+		writers[level] = []io.Writer{w}
+	}
+	return newRoutedHook(writers, formatter)
+}
+
+func newRoutedHook(writers map[Level][]io.Writer, formatter Formatter) *LfsHook {
 	hook := &LfsHook{
-		lock:    new(sync.Mutex),
-		writers: output,
+		lock:     new(sync.Mutex),
+		writers:  writers,
+		minLevel: uint32(PanicLevel),
+		maxLevel: uint32(TraceLevel),
 	}
 	hook.SetFormatter(formatter)
-	for level := range output {
-		hook.levels = append(hook.levels, level)
-	}
 	return hook
 }
 
+// NewPathMapHook returns a hook writing the levels in paths to rotating files
+// and the levels in writers to the given writers; a level may appear in only
+// one of them. Each path becomes the link to its current file, so
+// "logs/errors.log" is rotated as logs/errors.<stamp>.log, and levels sharing
+// a path share one RotatingFileWriter. template supplies the remaining
+// RotatingFileConfig settings; its Dir, BaseName, Ext and LinkName are set
+// per path. The hook is not added to any logger. The returned Closer closes
+// the files, which RotateLocalFileSystemLogs and ReopenLocalFileSystemLogs
+// cover until then.
+func NewPathMapHook(paths PathMap, writers WriterMap, template RotatingFileConfig, formatter Formatter) (*LfsHook, io.Closer, error) {
+	output := make(WriterMap, len(paths)+len(writers))
+	for level, w := range writers {
+		output[level] = w
+	}
+	closer := &lfsCloser{}
+	byPath := make(map[string]*RotatingFileWriter)
+	for _, level := range AllLevels {
+		path, ok := paths[level]
+		if !ok {
+			continue
+		}
+		if _, dup := writers[level]; dup {
+			closer.Close()
+			return nil, nil, fmt.Errorf("level %s has both a path and a writer", level)
+		}
+		key, err := filepath.Abs(path)
+		if err != nil {
+			key = filepath.Clean(path)
+		}
+		w, ok := byPath[key]
+		if !ok {
+			cfg := template
+			cfg.Dir = filepath.Dir(path)
+			cfg.LinkName = filepath.Base(path)
+			cfg.Ext = filepath.Ext(cfg.LinkName)
+			cfg.BaseName = strings.TrimSuffix(cfg.LinkName, cfg.Ext)
+			if w, err = NewRotatingFileWriter(cfg); err != nil {
+				closer.Close()
+				return nil, nil, fmt.Errorf("create writer for %s: %w", path, err)
+			}
+			closer.writers = append(closer.writers, w)
+			byPath[key] = w
+		}
+		output[level] = w
+	}
+	registerLfsWriters(closer.writers...)
+	closer.hook = newLocalFileSystemHook(output, formatter)
+	return closer.hook, closer, nil
+}
+
 func (hook *LfsHook) SetFormatter(formatter Formatter) {
 	hook.lock.Lock()
 	defer hook.lock.Unlock()
 	if formatter == nil {
 		formatter = defaultFormatter
-	} else {
-		if tf, ok := formatter.(*TextFormatter); ok {
-			tf.DisableColors = true
+	}
+	hook.formatter = fileFormatter(formatter)
+}
+
+// SetLevelFormatter formats entries of level with formatter, e.g. JSON for
+// errors feeding an alerting pipeline. A nil formatter removes the override.
+func (hook *LfsHook) SetLevelFormatter(level Level, formatter Formatter) {
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	if formatter == nil {
+		delete(hook.levelFormatters, level)
+		return
+	}
+	if hook.levelFormatters == nil {
+		hook.levelFormatters = make(map[Level]Formatter)
+	}
+	hook.levelFormatters[level] = fileFormatter(formatter)
+}
+
+// SetWriterFormatter formats everything written to writer with formatter,
+// whatever the level. writer must be comparable, such as a pointer. A nil
+// formatter removes the override.
+func (hook *LfsHook) SetWriterFormatter(writer io.Writer, formatter Formatter) {
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	kept := hook.writerFormatters[:0]
+	for _, wf := range hook.writerFormatters {
+		if !sameValue(wf.writer, writer) {
+			kept = append(kept, wf)
+		}
+	}
+	hook.writerFormatters = kept
+	if formatter != nil {
+		hook.writerFormatters = append(hook.writerFormatters, writerFormatter{writer, fileFormatter(formatter)})
+	}
+}
+
+// fileFormatter turns off colors, which only make sense on a terminal.
+func fileFormatter(formatter Formatter) Formatter {
+	if tf, ok := formatter.(*TextFormatter); ok {
+		tf.DisableColors = true
+	}
+	return formatter
+}
+
+// sameValue compares two interface values without panicking on
+// uncomparable dynamic types.
+func sameValue(a, b interface{}) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t != nil && t.Comparable() && a == b
+}
+
+// formatterFor returns the formatter for writing an entry of level to w.
+// Callers hold hook.lock.
+func (hook *LfsHook) formatterFor(level Level, w io.Writer) Formatter {
+	for _, wf := range hook.writerFormatters {
+		if sameValue(wf.writer, w) {
+			return wf.formatter
 		}
 	}
-	hook.formatter = formatter
+	if f, ok := hook.levelFormatters[level]; ok {
+		return f
+	}
+	return hook.formatter
 }
 
+// SetDefaultWriter sends entries of levels without a writer to
+// defaultWriter. Call it before the hook is added to a logger, which asks
+// for Levels only then; LfsOptions.DefaultWriter does this for
+// NewLocalFileSystemHook.
 func (hook *LfsHook) SetDefaultWriter(defaultWriter io.Writer) {
 	hook.lock.Lock()
 	defer hook.lock.Unlock()
@@ -50,29 +191,102 @@ func (hook *LfsHook) SetDefaultWriter(defaultWriter io.Writer) {
 	hook.hasDefaultWriter = true
 }
 
+// SetLevel makes the hook skip entries more verbose than level, e.g. files
+// get Info and above while the console gets Debug. It may be called while
+// the hook is in use and is independent of Logger.HookLevel, which still
+// applies first.
+func (hook *LfsHook) SetLevel(level Level) {
+	atomic.StoreUint32(&hook.maxLevel, uint32(level))
+}
+
+// SetLevelRange makes the hook write only entries from the most severe level
+// min to the most verbose level max, e.g. WarnLevel to InfoLevel.
+func (hook *LfsHook) SetLevelRange(min, max Level) {
+	atomic.StoreUint32(&hook.minLevel, uint32(min))
+	atomic.StoreUint32(&hook.maxLevel, uint32(max))
+}
+
+// GetLevel returns the most verbose level the hook writes.
+func (hook *LfsHook) GetLevel() Level {
+	return Level(atomic.LoadUint32(&hook.maxLevel))
+}
+
+func (hook *LfsHook) enabled(level Level) bool {
+	return uint32(level) >= atomic.LoadUint32(&hook.minLevel) &&
+		uint32(level) <= atomic.LoadUint32(&hook.maxLevel)
+}
+
 func (hook *LfsHook) Fire(entry *Entry) error {
+	if !hook.enabled(entry.Level) {
+		return nil
+	}
+	if q := hook.async.Load(); q != nil {
+		if written, err := q.fire(hook, entry); written {
+			return err
+		}
+	}
+	return hook.write(entry)
+}
+
+// write formats entry and writes it to the writers of its level.
+func (hook *LfsHook) write(entry *Entry) error {
 	hook.lock.Lock()
 	defer hook.lock.Unlock()
 
-	var writer io.Writer
-	var ok bool
-	if writer, ok = hook.writers[entry.Level]; !ok {
-		if hook.hasDefaultWriter {
-			writer = hook.defaultWriter
-		} else {
+	writers, ok := hook.writers[entry.Level]
+	if !ok {
+		if !hook.hasDefaultWriter {
 			return nil
 		}
+		writers = []io.Writer{hook.defaultWriter}
 	}
 
-	msg, err := hook.formatter.Format(entry)
-	if err != nil {
-		log.Println("failed to generate string for entry:", err)
-		return err
+	// Format once per distinct formatter, however many writers share it.
+	type formatted struct {
+		formatter Formatter
+		msg       []byte
 	}
-	_, err = writer.Write(msg)
-	return err
+	var done []formatted
+	var errs []error
+	for _, writer := range writers {
+		formatter := hook.formatterFor(entry.Level, writer)
+		var msg []byte
+		for _, f := range done {
+			if sameValue(f.formatter, formatter) {
+				msg = f.msg
+				break
+			}
+		}
+		if msg == nil {
+			var err error
+			if msg, err = formatter.Format(entry); err != nil {
+				log.Println("failed to generate string for entry:", err)
+				errs = append(errs, err)
+				continue
+			}
+			done = append(done, formatted{formatter, msg})
+		}
+		if _, err := writer.Write(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
+// Levels returns the levels that have a writer, or all of them once a
+// default writer is set. The range set with SetLevel or SetLevelRange is
+// checked in Fire instead, so it can change after the hook is added.
 func (hook *LfsHook) Levels() []Level {
-	return AllLevels
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	if hook.hasDefaultWriter {
+		return AllLevels
+	}
+	var levels []Level
+	for _, level := range AllLevels {
+		if _, ok := hook.writers[level]; ok {
+			levels = append(levels, level)
+		}
+	}
+	return levels
 }
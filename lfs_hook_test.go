@@ -0,0 +1,149 @@
+package logrus
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPathMapHook(t *testing.T) {
+	dir := t.TempDir()
+	errorsPath := filepath.Join(dir, "errors.log")
+	appPath := filepath.Join(dir, "app.log")
+	var debug bytes.Buffer
+	hook, closer, err := NewPathMapHook(PathMap{
+		PanicLevel: errorsPath,
+		FatalLevel: errorsPath,
+		ErrorLevel: errorsPath,
+		WarnLevel:  appPath,
+		InfoLevel:  appPath,
+	}, WriterMap{
+		DebugLevel: &debug,
+	}, RotatingFileConfig{}, &TextFormatter{DisableTimestamp: true})
+	require.NoError(t, err)
+
+	writers := closer.(*lfsCloser).writers
+	require.Len(t, writers, 2, "levels sharing a path share a writer")
+	assert.Same(t, hook.writers[FatalLevel][0], hook.writers[ErrorLevel][0])
+	assert.Same(t, hook.writers[WarnLevel][0], hook.writers[InfoLevel][0])
+	assert.Equal(t, "errors", writers[0].baseName)
+	assert.Equal(t, "errors.log", writers[0].linkName)
+
+	logger := New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	logger.Error("failed")
+	logger.Info("started")
+	logger.Debug("details")
+	require.NoError(t, closer.Close())
+
+	read := func(w *RotatingFileWriter) string {
+		b, err := os.ReadFile(w.path)
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.Contains(t, read(writers[0]), "failed")
+	assert.NotContains(t, read(writers[0]), "started")
+	assert.Contains(t, read(writers[1]), "started")
+	assert.Contains(t, debug.String(), "details")
+}
+
+func TestNewPathMapHookRejectsConflicts(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := NewPathMapHook(PathMap{
+		InfoLevel:  filepath.Join(dir, "app.log"),
+		ErrorLevel: filepath.Join(dir, "errors.log"),
+	}, WriterMap{ErrorLevel: io.Discard}, RotatingFileConfig{}, nil)
+	assert.Error(t, err)
+}
+
+func TestLfsHookLevels(t *testing.T) {
+	hook := newLocalFileSystemHook(WriterMap{
+		ErrorLevel: io.Discard,
+		InfoLevel:  io.Discard,
+		DebugLevel: io.Discard,
+	}, nil)
+	assert.Equal(t, []Level{ErrorLevel, InfoLevel, DebugLevel}, hook.Levels())
+
+	hook.SetDefaultWriter(io.Discard)
+	assert.Equal(t, AllLevels, hook.Levels())
+}
+
+func TestLfsHookLevelThreshold(t *testing.T) {
+	var out bytes.Buffer
+	hook := newLocalFileSystemHook(WriterMap{
+		WarnLevel:  &out,
+		InfoLevel:  &out,
+		DebugLevel: &out,
+	}, &TextFormatter{DisableTimestamp: true})
+	logger := New()
+	logger.Out = io.Discard
+	logger.SetLevel(DebugLevel)
+	logger.AddHook(hook)
+
+	hook.SetLevel(InfoLevel)
+	assert.Equal(t, InfoLevel, hook.GetLevel())
+	logger.Debug("hidden")
+	logger.Info("shown")
+	assert.NotContains(t, out.String(), "hidden")
+	assert.Contains(t, out.String(), "shown")
+
+	// The threshold can be raised after the hook was added.
+	hook.SetLevel(DebugLevel)
+	logger.Debug("now shown")
+	assert.Contains(t, out.String(), "now shown")
+
+	out.Reset()
+	hook.SetLevelRange(InfoLevel, DebugLevel)
+	logger.Warn("too severe")
+	logger.Info("in range")
+	assert.NotContains(t, out.String(), "too severe")
+	assert.Contains(t, out.String(), "in range")
+}
+
+type countingFormatter struct {
+	Formatter
+	calls int
+}
+
+func (f *countingFormatter) Format(entry *Entry) ([]byte, error) {
+	f.calls++
+	return f.Formatter.Format(entry)
+}
+
+func TestLfsHookFormatters(t *testing.T) {
+	var errorsOut, common, info bytes.Buffer
+	text := &countingFormatter{Formatter: &TextFormatter{DisableTimestamp: true}}
+	json := &countingFormatter{Formatter: &JSONFormatter{DisableTimestamp: true}}
+	hook := newRoutedHook(map[Level][]io.Writer{
+		ErrorLevel: {&errorsOut, &common},
+		InfoLevel:  {&info, &common},
+	}, text)
+	hook.SetWriterFormatter(&errorsOut, json)
+
+	logger := New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	logger.Error("failed")
+	assert.Contains(t, errorsOut.String(), `"msg":"failed"`)
+	assert.Contains(t, common.String(), "msg=failed")
+	assert.Equal(t, 1, json.calls)
+	assert.Equal(t, 1, text.calls)
+
+	// Writers sharing a formatter get one formatted entry.
+	logger.Info("started")
+	assert.Equal(t, 2, text.calls)
+	assert.Contains(t, info.String(), "msg=started")
+	assert.Contains(t, common.String(), "msg=started")
+
+	hook.SetLevelFormatter(InfoLevel, json)
+	logger.Info("as json")
+	assert.Contains(t, info.String(), `"msg":"as json"`)
+	assert.Contains(t, common.String(), `"msg":"as json"`)
+	assert.Equal(t, 2, json.calls)
+}
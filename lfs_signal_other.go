@@ -0,0 +1,10 @@
+//go:build !unix
+
+package logrus
+
+// HandleLocalFileSystemSignals is a no-op on platforms without SIGHUP and
+// SIGUSR1; use RotateLocalFileSystemLogs and ReopenLocalFileSystemLogs
+// directly instead.
+func HandleLocalFileSystemSignals() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package logrus
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleLocalFileSystemSignals makes the writers created by
+// NewLocalFileSystemHook reopen their files on SIGHUP, as expected by
+// logrotate's "create" mode, and rotate on SIGUSR1. The returned function
+// stops handling the signals.
+func HandleLocalFileSystemSignals() (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGHUP, syscall.SIGUSR1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-ch:
+				var err error
+				if sig == syscall.SIGHUP {
+					err = ReopenLocalFileSystemLogs()
+				} else {
+					err = RotateLocalFileSystemLogs()
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to handle %v for log files: %v\n", sig, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
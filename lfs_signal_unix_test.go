@@ -0,0 +1,29 @@
+//go:build unix
+
+package logrus
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleLocalFileSystemSignals(t *testing.T) {
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: t.TempDir(), BaseName: "app"})
+	require.NoError(t, err)
+	defer w.Close()
+	registerLfsWriters(w)
+	defer unregisterLfsWriters(w)
+
+	stop := HandleLocalFileSystemSignals()
+	defer stop()
+	first := w.currentPath()
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	assert.Eventually(t, func() bool {
+		return w.currentPath() != first
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -51,6 +51,11 @@ type Logger struct {
 	// The buffer pool used to format the log. If it is nil, the default global
 	// buffer pool will be used.
 	BufferPool BufferPool
+	// Clock stamps entries that have no time set. If it is nil, the wall
+	// clock is used.
+	Clock Clock
+	// Levels of named loggers, see SetModuleLevel.
+	modules atomic.Pointer[moduleLevels]
 }
 
 type exitFunc func(int)
@@ -127,6 +132,7 @@ func (logger *Logger) releaseEntry(entry *Entry) {
 	entry.err = ""
 	entry.Context = nil
 	entry.Buffer = nil
+	entry.module = ""
 	logger.entryPool.Put(entry)
 }
 
@@ -600,6 +606,33 @@ func (logger *Logger) ReplaceHooks(hooks LevelHooks) LevelHooks {
 	return oldHooks
 }
 
+// removeHook removes every registration of hook. The slices are rebuilt, not
+// filtered in place, since entries being logged may hold the old ones.
+func (logger *Logger) removeHook(hook Hook) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	hooks := make(LevelHooks, len(logger.Hooks))
+	for level, levelHooks := range logger.Hooks {
+		var kept []Hook
+		for _, h := range levelHooks {
+			if h != hook {
+				kept = append(kept, h)
+			}
+		}
+		if len(kept) > 0 {
+			hooks[level] = kept
+		}
+	}
+	logger.Hooks = hooks
+}
+
+// SetClock sets the clock used to timestamp entries.
+func (logger *Logger) SetClock(clock Clock) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.Clock = clock
+}
+
 // SetBufferPool sets the logger buffer pool.
 func (logger *Logger) SetBufferPool(pool BufferPool) {
 	logger.mu.Lock()
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/bnulwh/logrus/clocktest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -98,3 +100,16 @@ func TestLogger_SetBufferPool(t *testing.T) {
 	assert.Equal(t, pool.get, 1, "Logger.SetBufferPool(): The BufferPool.Get() must be called")
 	assert.Len(t, pool.buffers, 1, "Logger.SetBufferPool(): The BufferPool.Put() must be called")
 }
+
+func TestLogger_SetClock(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := New()
+	l.SetOutput(out)
+	l.SetFormatter(&SimpleFormatter{})
+	l.SetReportCaller(false)
+	l.SetClock(clocktest.NewFakeClock(time.Date(2026, 10, 16, 15, 4, 5, 0, time.Local)))
+
+	l.Info("tick")
+
+	assert.Equal(t, "[2026-10-16 15:04:05.000] [   info] tick\n", out.String())
+}
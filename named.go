@@ -0,0 +1,117 @@
+package logrus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Defines the key holding the name of entries returned by Named.
+var LoggerKey = "logger"
+
+// moduleLevels maps logger names to levels. It is never modified once
+// published, so readers need no lock.
+type moduleLevels map[string]Level
+
+// Named returns an entry for the component name, logged with a LoggerKey
+// field. Names are dot separated paths such as "billing.invoice"; levels set
+// with SetModuleLevel or SetModuleLevels for the name or one of its prefixes
+// apply to the entry and every entry derived from it.
+func (logger *Logger) Named(name string) *Entry {
+	entry := logger.WithField(LoggerKey, name)
+	entry.module = name
+	return entry
+}
+
+// Named returns a child of the entry's named logger, or a top level one if
+// the entry has no name.
+func (entry *Entry) Named(name string) *Entry {
+	if entry.module != "" {
+		name = entry.module + "." + name
+	}
+	child := entry.WithField(LoggerKey, name)
+	child.module = name
+	return child
+}
+
+// SetModuleLevel sets the level of the named logger name and of its
+// descendants that have no level of their own. It replaces both ConsoleLevel
+// and HookLevel for them and takes effect immediately.
+func (logger *Logger) SetModuleLevel(name string, level Level) {
+	for {
+		old := logger.modules.Load()
+		levels := moduleLevels{name: level}
+		if old != nil {
+			for k, v := range *old {
+				if k != name {
+					levels[k] = v
+				}
+			}
+		}
+		if logger.modules.CompareAndSwap(old, &levels) {
+			return
+		}
+	}
+}
+
+// SetModuleLevels replaces every module level with those in spec, a comma
+// separated list of name=level pairs such as
+// "billing=debug,billing.invoice=trace". An empty spec clears them. On error
+// the levels are left unchanged.
+func (logger *Logger) SetModuleLevels(spec string) error {
+	levels := make(moduleLevels)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, lvl, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid module level %q, want name=level", part)
+		}
+		level, err := ParseLevel(strings.TrimSpace(lvl))
+		if err != nil {
+			return fmt.Errorf("module %s: %w", name, err)
+		}
+		levels[name] = level
+	}
+	logger.modules.Store(&levels)
+	return nil
+}
+
+// ModuleLevel returns the level set for name or its longest prefix, and
+// false if there is none.
+func (logger *Logger) ModuleLevel(name string) (Level, bool) {
+	levels := logger.modules.Load()
+	if levels == nil || name == "" {
+		return 0, false
+	}
+	for {
+		if level, ok := (*levels)[name]; ok {
+			return level, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return 0, false
+		}
+		name = name[:i]
+	}
+}
+
+// levels returns the console and hook levels that apply to the entry: its
+// module's level for both when one is set, otherwise the logger's.
+func (entry *Entry) levels() (console, hook Level) {
+	if entry.module != "" {
+		if level, ok := entry.Logger.ModuleLevel(entry.module); ok {
+			return level, level
+		}
+	}
+	return entry.Logger.consoleLevel(), entry.Logger.hookLevel()
+}
+
+// IsLevelEnabled checks if level is logged by the entry, taking the level of
+// its named logger into account.
+func (entry *Entry) IsLevelEnabled(level Level) bool {
+	console, hook := entry.levels()
+	return console >= level || hook >= level
+}
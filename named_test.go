@@ -0,0 +1,127 @@
+package logrus
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type messageHook struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (h *messageHook) Levels() []Level { return AllLevels }
+
+func (h *messageHook) Fire(entry *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, entry.Message)
+	return nil
+}
+
+func newNamedTestLogger(buf *bytes.Buffer) *Logger {
+	logger := New()
+	logger.Out = buf
+	logger.Formatter = new(JSONFormatter)
+	logger.SetLevel(InfoLevel)
+	return logger
+}
+
+func messages(t *testing.T, buf *bytes.Buffer) []string {
+	var msgs []string
+	for _, line := range decodeLines(t, buf) {
+		msgs = append(msgs, line["msg"].(string))
+	}
+	return msgs
+}
+
+func TestNamed(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newNamedTestLogger(&buf)
+	logger.Named("billing").WithField("id", 1).Named("invoice").Info("sent")
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "billing.invoice", lines[0][LoggerKey])
+	assert.Equal(t, float64(1), lines[0]["id"])
+}
+
+func TestModuleLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newNamedTestLogger(&buf)
+	hook := new(messageHook)
+	logger.AddHook(hook)
+	require.NoError(t, logger.SetModuleLevels(" billing=debug, billing.invoice=trace "))
+
+	billing := logger.Named("billing")
+	invoice := billing.Named("invoice")
+	billing.Debug("billing debug")
+	billing.Trace("billing trace")
+	invoice.Trace("invoice trace")
+	logger.Named("billingx").Debug("billingx debug")
+	logger.Named("shipping").Debug("shipping debug")
+	logger.Debug("root debug")
+	logger.Named("shipping").Info("shipping info")
+
+	want := []string{"billing debug", "invoice trace", "shipping info"}
+	assert.Equal(t, want, messages(t, &buf))
+	assert.Equal(t, want, hook.messages)
+
+	// Derived entries keep the module.
+	assert.True(t, invoice.WithField("k", "v").IsLevelEnabled(TraceLevel))
+	assert.True(t, invoice.WithContext(context.Background()).IsLevelEnabled(TraceLevel))
+	assert.True(t, invoice.WithTime(time.Now()).IsLevelEnabled(TraceLevel))
+	assert.True(t, invoice.Dup().IsLevelEnabled(TraceLevel))
+
+	// Levels change at runtime, for entries already handed out.
+	logger.SetModuleLevel("billing", WarnLevel)
+	billing.Info("billing info")
+	invoice.Trace("invoice trace 2")
+	require.NoError(t, logger.SetModuleLevels(""))
+	invoice.Trace("invoice trace 3")
+	invoice.Info("invoice info")
+	assert.Equal(t, []string{"invoice trace 2", "invoice info"}, messages(t, &buf))
+
+	level, ok := logger.ModuleLevel("billing.invoice.pdf")
+	assert.False(t, ok)
+	assert.Equal(t, Level(0), level)
+}
+
+func TestSetModuleLevelsInvalid(t *testing.T) {
+	logger := newNamedTestLogger(new(bytes.Buffer))
+	logger.SetModuleLevel("billing", DebugLevel)
+	for _, spec := range []string{"billing", "=debug", "billing=loud"} {
+		assert.Error(t, logger.SetModuleLevels(spec), spec)
+	}
+	level, ok := logger.ModuleLevel("billing.invoice")
+	assert.True(t, ok, "levels are kept on error")
+	assert.Equal(t, DebugLevel, level)
+}
+
+func TestModuleLevelsConcurrent(t *testing.T) {
+	logger := newNamedTestLogger(new(bytes.Buffer))
+	entry := logger.Named("billing.invoice")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				entry.Debug("tick")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.SetModuleLevel("billing", Level(j%7))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,121 @@
+package logrus
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archivePath returns where a finished file belongs under ArchiveDir.
+func (w *RotatingFileWriter) archivePath(rf rotatedFile) string {
+	dir := w.archiveDir
+	if w.archiveLayout != "" {
+		dir = filepath.Join(dir, filepath.FromSlash(rf.time.In(w.loc).Format(w.archiveLayout)))
+	}
+	return filepath.Join(dir, filepath.Base(rf.path))
+}
+
+// inArchive reports whether path lies under ArchiveDir.
+func (w *RotatingFileWriter) inArchive(path string) bool {
+	if w.archiveDir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(w.archiveDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// archive moves a finished file into ArchiveDir and returns its new path.
+// Files on another file system are copied and then removed. In MultiProcess
+// mode the caller holds a claim on path.
+func (w *RotatingFileWriter) archive(path string) (string, error) {
+	rf, ok := w.parseRotatedName(filepath.Base(path))
+	if !ok {
+		return "", fmt.Errorf("archive %s: not a rotated file name", path)
+	}
+	rf.path = path
+	dst := w.archivePath(rf)
+	if dst == path {
+		return path, nil
+	}
+	if err := w.makeDir(filepath.Dir(dst)); err != nil {
+		return "", err
+	}
+	unlock := w.lockDir()
+	err := w.moveLocked(path, dst)
+	unlock()
+	if err == nil {
+		return dst, nil
+	}
+	if _, serr := os.Lstat(path); serr != nil {
+		return "", err
+	}
+	// Most likely a different file system.
+	tmp := dst + ".tmp"
+	if err := w.copyFile(path, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("archive %s: %w", path, err)
+	}
+	unlock = w.lockDir()
+	defer unlock()
+	if err := w.moveLocked(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Remove(path); err != nil {
+		return dst, fmt.Errorf("remove archived file %s: %w", path, err)
+	}
+	return dst, nil
+}
+
+// moveLocked renames src to dst without replacing an existing file. Callers
+// hold lockDir.
+func (w *RotatingFileWriter) moveLocked(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("archive %s: %s already exists", src, dst)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("archive %s: %w", src, err)
+	}
+	return nil
+}
+
+func (w *RotatingFileWriter) copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, w.createMode())
+	if err != nil {
+		return err
+	}
+	if err := w.applyFilePerm(out, nil); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// pruneArchiveDirs removes directories under ArchiveDir that were emptied by
+// retention, from the parent of path upwards.
+func (w *RotatingFileWriter) pruneArchiveDirs(path string) {
+	if w.archiveLayout == "" || !w.inArchive(path) {
+		return
+	}
+	root := filepath.Clean(w.archiveDir)
+	for dir := filepath.Dir(path); dir != root && w.inArchive(dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
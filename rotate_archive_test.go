@@ -0,0 +1,107 @@
+package logrus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileWriterArchivesByDate(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, "archive")
+	clock := clocktest.NewFakeClock(testNow)
+	rotated := make(chan string, 1)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:           dir,
+		BaseName:      "app",
+		Clock:         clock,
+		Compress:      &GzipCompressor{},
+		ArchiveDir:    archiveDir,
+		ArchiveLayout: "2006/01/02",
+		OnRotate:      func(oldPath, newPath string) { rotated <- oldPath },
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	first := w.currentPath()
+	_, err = w.Write([]byte("archived\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("current\n"))
+	require.NoError(t, err)
+
+	want := filepath.Join(archiveDir, "2026", "10", "16", filepath.Base(first)+".gz")
+	select {
+	case path := <-rotated:
+		assert.Equal(t, want, path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotated file was not archived")
+	}
+	assert.Equal(t, "archived\n", readGzip(t, want))
+	assert.False(t, exists(first))
+	assert.False(t, exists(first+".gz"))
+}
+
+func TestRotatingFileWriterRetentionCoversArchive(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, "archive")
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:           dir,
+		BaseName:      "app",
+		Clock:         clock,
+		MaxAge:        48 * time.Hour,
+		MaxBackups:    2,
+		ArchiveDir:    archiveDir,
+		ArchiveLayout: "2006/01/02",
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	seed := func(age time.Duration) string {
+		rf := rotatedFile{time: w.periodStart(testNow.Add(-age))}
+		rf.path = w.rotatedFileName(rf.time, 0)
+		path := w.archivePath(rf)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+		return path
+	}
+	expired := seed(72 * time.Hour)
+	oldest := seed(26 * time.Hour)
+	kept := []string{seed(2 * time.Hour), seed(time.Hour)}
+
+	w.removeOldFiles(clock.Now().Add(-w.maxAge))
+
+	assert.False(t, exists(expired))
+	assert.False(t, exists(filepath.Dir(expired)), "emptied date directories are pruned")
+	assert.False(t, exists(oldest), "MaxBackups counts archived files")
+	for _, path := range kept {
+		assert.True(t, exists(path), path)
+	}
+	assert.True(t, exists(archiveDir))
+}
+
+func TestRotatingFileWriterArchivesLeftoverFiles(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, "archive")
+	leftover := filepath.Join(dir, "app."+testNow.Add(-3*time.Hour).Format(rotatedTimeLayout)+".log")
+	require.NoError(t, os.WriteFile(leftover, []byte("old\n"), 0644))
+
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        dir,
+		BaseName:   "app",
+		Clock:      clocktest.NewFakeClock(testNow),
+		ArchiveDir: archiveDir,
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.False(t, exists(leftover))
+	assert.Equal(t, "old\n", readString(t, filepath.Join(archiveDir, filepath.Base(leftover))))
+	assert.True(t, exists(w.currentPath()), "the current file stays in Dir")
+}
@@ -0,0 +1,469 @@
+package logrus
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Audit files frame every write as one record:
+//
+//	#audit seq=<n> [prev=<hex>] len=<bytes> mac=<hex>\n<bytes>
+//
+// mac is HMAC-SHA256 over the previous record's mac, seq, the record kind
+// and the bytes, so each record vouches for everything before it. prev
+// repeats the previous mac on the first record a writer puts in a file,
+// letting a verifier anchor the chain when older files are gone. Before a
+// file is closed a manifest record, framed as "#audit-manifest", states how
+// many records precede it in the file.
+const (
+	auditRecordTag   = "#audit"
+	auditManifestTag = "#audit-manifest"
+)
+
+const (
+	auditKindRecord byte = iota
+	auditKindManifest
+)
+
+var errAuditMultiProcess = errors.New("audit logs cannot be shared between processes")
+
+// AuditWriter is a RotatingFileWriter whose files form a tamper-evident
+// HMAC-SHA256 hash chain. Each Write is one record, so it works with any
+// Formatter; use VerifyAuditLogs or Verify to check the files.
+type AuditWriter struct {
+	w   *RotatingFileWriter
+	key []byte
+
+	// The chain state is guarded by w.mu; frame, unframe and seal run under
+	// it. undo is the state before the last frame or seal.
+	auditState
+	undo auditState
+}
+
+type auditState struct {
+	seq      uint64
+	prev     []byte
+	needPrev bool
+	first    uint64
+	count    uint64
+}
+
+// NewAuditWriter opens a RotatingFileWriter for cfg and chains its records
+// with key. The chain continues from the newest existing file. MultiProcess
+// is not supported, as processes would fork the chain, and neither is
+// Encrypt.
+func NewAuditWriter(cfg RotatingFileConfig, key []byte) (*AuditWriter, error) {
+	if len(key) == 0 {
+		return nil, errors.New("audit writer needs a key")
+	}
+	if cfg.MultiProcess {
+		return nil, errAuditMultiProcess
+	}
+	if cfg.Encrypt != nil {
+		return nil, errors.New("audit writer does not support Encrypt")
+	}
+	w, err := NewRotatingFileWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a := &AuditWriter{w: w, key: append([]byte(nil), key...)}
+	w.mu.Lock()
+	err = a.recover()
+	if err == nil {
+		w.frame = a.frame
+		w.unframe = a.unframe
+		w.seal = a.seal
+		w.trimTail = trimAuditTail
+	}
+	w.mu.Unlock()
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditWriter) Write(p []byte) (int, error) {
+	return a.w.Write(p)
+}
+
+// Rotate seals the current file and continues in a new one.
+func (a *AuditWriter) Rotate() error {
+	return a.w.Rotate()
+}
+
+// Flush writes buffered records to the file.
+func (a *AuditWriter) Flush() error {
+	return a.w.Flush()
+}
+
+// Close seals and closes the current file.
+func (a *AuditWriter) Close() error {
+	return a.w.Close()
+}
+
+// Verify checks every file of the writer, oldest first. The current file
+// need not end with a manifest.
+func (a *AuditWriter) Verify() error {
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	var paths []string
+	current := a.w.currentPath()
+	files := a.w.listRotated()
+	for i := len(files) - 1; i >= 0; i-- {
+		if !files[i].partial && files[i].path != current {
+			paths = append(paths, files[i].path)
+		}
+	}
+	if current != "" {
+		paths = append(paths, current)
+	}
+	return VerifyAuditLogs(a.key, paths...)
+}
+
+func (a *AuditWriter) mac(kind byte, payload []byte) []byte {
+	m := hmac.New(sha256.New, a.key)
+	m.Write(a.prev)
+	var n [9]byte
+	binary.BigEndian.PutUint64(n[:8], a.seq)
+	n[8] = kind
+	m.Write(n[:])
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// next appends the record for payload to the chain and returns it framed.
+func (a *AuditWriter) next(kind byte, payload []byte) []byte {
+	a.seq++
+	var b bytes.Buffer
+	tag := auditRecordTag
+	if kind == auditKindManifest {
+		tag = auditManifestTag
+	}
+	fmt.Fprintf(&b, "%s seq=%d ", tag, a.seq)
+	if a.needPrev {
+		fmt.Fprintf(&b, "prev=%x ", a.prev)
+		a.needPrev = false
+	}
+	mac := a.mac(kind, payload)
+	fmt.Fprintf(&b, "len=%d mac=%x\n", len(payload), mac)
+	b.Write(payload)
+	a.prev = mac
+	return b.Bytes()
+}
+
+func (a *AuditWriter) frame(p []byte) []byte {
+	a.undo = a.auditState
+	if a.count == 0 {
+		a.first = a.seq + 1
+	}
+	a.count++
+	return a.next(auditKindRecord, p)
+}
+
+// unframe takes back the last record, which never reached the file.
+func (a *AuditWriter) unframe() {
+	a.auditState = a.undo
+}
+
+func (a *AuditWriter) seal() []byte {
+	a.undo = a.auditState
+	first := a.first
+	if a.count == 0 {
+		first = a.seq + 1
+	}
+	body := fmt.Sprintf("first=%d records=%d\n", first, a.count)
+	a.count = 0
+	rec := a.next(auditKindManifest, []byte(body))
+	// The next file starts with prev so it can be verified on its own.
+	a.needPrev = true
+	return rec
+}
+
+// recover picks up the chain from the newest file holding records. Records
+// in the current file since its last manifest count towards the next one.
+// Callers hold a.w.mu.
+func (a *AuditWriter) recover() error {
+	a.prev = make([]byte, sha256.Size)
+	a.needPrev = true
+	// The current file is not listed in LinkCurrentFile mode.
+	paths := []string{a.w.path}
+	for _, rf := range a.w.listRotated() {
+		if !rf.partial && rf.path != a.w.path {
+			paths = append(paths, rf.path)
+		}
+	}
+	for _, path := range paths {
+		var (
+			found bool
+			count uint64
+			first uint64
+			end   int64
+		)
+		err := readAuditFile(path, func(rec auditRecord) error {
+			found = true
+			end = rec.end
+			a.seq = rec.seq
+			a.prev = rec.mac
+			if rec.kind == auditKindManifest {
+				count = 0
+			} else {
+				if count == 0 {
+					first = rec.seq
+				}
+				count++
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errAuditTorn) {
+			return fmt.Errorf("recover audit chain from %s: %w", path, err)
+		}
+		if err != nil && path == a.w.path && a.w.file != nil {
+			// Appending after a record torn by a crash would bury the
+			// next one in its payload.
+			if err := a.w.file.Truncate(end); err != nil {
+				return fmt.Errorf("truncate torn audit file %s: %w", path, err)
+			}
+			a.w.fileSize = end
+		}
+		if found {
+			if path == a.w.path {
+				a.count, a.first = count, first
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// AuditError reports where an audit chain fails verification.
+type AuditError struct {
+	Path string
+	// Seq is the sequence number of the offending record, or of the last
+	// good one when a record is missing.
+	Seq    uint64
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("audit log %s: record %d: %s", e.Path, e.Seq, e.Reason)
+}
+
+// VerifyAuditLogs checks that the audit files at paths, oldest first, form
+// one unbroken chain under key: every record is authentic, none is missing,
+// reordered or edited, and every file but the last ends with a manifest
+// matching its contents. Compressed files are read transparently. If the
+// first file does not start the chain, its first record must carry prev and
+// anchors the check. The first failure is returned as an *AuditError.
+func VerifyAuditLogs(key []byte, paths ...string) error {
+	a := &AuditWriter{key: key}
+	known := false
+	for i, path := range paths {
+		var (
+			last   uint64
+			sealed bool
+			count  uint64
+			first  uint64
+		)
+		fail := func(seq uint64, format string, args ...interface{}) error {
+			return &AuditError{Path: path, Seq: seq, Reason: fmt.Sprintf(format, args...)}
+		}
+		err := readAuditFile(path, func(rec auditRecord) error {
+			switch {
+			case known && rec.seq != a.seq+1:
+				return fail(a.seq, "next record is %d, records are missing or reordered", rec.seq)
+			case known && rec.prev != nil && !hmac.Equal(rec.prev, a.prev):
+				return fail(rec.seq, "does not continue the chain")
+			case !known && rec.prev != nil:
+				a.prev = rec.prev
+			case !known && rec.seq == 1:
+				a.prev = make([]byte, sha256.Size)
+			case !known:
+				return fail(rec.seq, "cannot anchor the chain")
+			}
+			a.seq = rec.seq
+			known = true
+			if !hmac.Equal(a.mac(rec.kind, rec.payload), rec.mac) {
+				return fail(rec.seq, "authentication failed, the record was altered")
+			}
+			a.prev = rec.mac
+			last = rec.seq
+			if rec.kind == auditKindRecord {
+				if count == 0 {
+					first = rec.seq
+				}
+				count++
+				sealed = false
+				return nil
+			}
+			if count == 0 {
+				first = rec.seq
+			}
+			var mFirst, mCount uint64
+			if _, err := fmt.Sscanf(string(rec.payload), "first=%d records=%d\n", &mFirst, &mCount); err != nil {
+				return fail(rec.seq, "malformed manifest")
+			}
+			if mFirst != first || mCount != count {
+				return fail(rec.seq, "manifest lists %d records from %d, found %d from %d", mCount, mFirst, count, first)
+			}
+			count = 0
+			sealed = true
+			return nil
+		})
+		var auditErr *AuditError
+		switch {
+		case errors.As(err, &auditErr):
+			return err
+		case errors.Is(err, errAuditTorn):
+			return fail(last, "truncated after this record")
+		case err != nil:
+			return fail(last, "%v", err)
+		case i < len(paths)-1 && !sealed:
+			return fail(last, "file is not sealed by a manifest, it was truncated or its writer crashed")
+		}
+	}
+	return nil
+}
+
+var errAuditTorn = errors.New("torn audit record")
+
+type auditRecord struct {
+	kind    byte
+	seq     uint64
+	prev    []byte
+	mac     []byte
+	payload []byte
+	// end is the offset just past the record.
+	end int64
+}
+
+// trimAuditTail returns the length of the audit file at path up to its last
+// complete record, and whether a torn record follows it.
+func trimAuditTail(path string) (int64, bool) {
+	var end int64
+	err := readAuditFile(path, func(rec auditRecord) error {
+		end = rec.end
+		return nil
+	})
+	return end, errors.Is(err, errAuditTorn)
+}
+
+// writeWhole writes p to the open file, cutting the file back to its
+// previous length if only part of p was written, so an AuditWriter's file
+// holds complete records and the rest stays buffered. Callers hold w.mu.
+func (w *RotatingFileWriter) writeWhole(p []byte) (int, error) {
+	end, err := w.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.file.Write(p)
+	if err != nil && n > 0 {
+		if terr := w.file.Truncate(end); terr != nil {
+			// trimTail cuts the torn record off when the file is reopened.
+			fmt.Fprintf(os.Stderr, "Failed to truncate log file: %v\n", terr)
+		}
+		n = 0
+	}
+	return n, err
+}
+
+// readAuditFile calls fn for each record in an audit file, gunzipping it if
+// needed. A record cut short by a crash or truncation yields errAuditTorn.
+func readAuditFile(path string, fn func(auditRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = bufio.NewReader(zr)
+	}
+	return readAuditRecords(r, fn)
+}
+
+func readAuditRecords(r *bufio.Reader, fn func(auditRecord) error) error {
+	var offset int64
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return nil
+		}
+		if err != nil {
+			return errAuditTorn
+		}
+		rec, size, err := parseAuditHeader(strings.TrimSuffix(header, "\n"))
+		if err != nil {
+			return err
+		}
+		// len is not trusted until the mac is checked, so the payload
+		// grows as it is read rather than being allocated up front.
+		var payload bytes.Buffer
+		n, err := payload.ReadFrom(io.LimitReader(r, size))
+		if err != nil || n < size {
+			return errAuditTorn
+		}
+		rec.payload = payload.Bytes()
+		offset += int64(len(header)) + size
+		rec.end = offset
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+func parseAuditHeader(line string) (auditRecord, int64, error) {
+	var rec auditRecord
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return rec, 0, fmt.Errorf("malformed audit header %q", line)
+	}
+	switch fields[0] {
+	case auditRecordTag:
+		rec.kind = auditKindRecord
+	case auditManifestTag:
+		rec.kind = auditKindManifest
+	default:
+		return rec, 0, fmt.Errorf("malformed audit header %q", line)
+	}
+	size := int64(-1)
+	var err error
+	for _, field := range fields[1:] {
+		k, v, _ := strings.Cut(field, "=")
+		switch k {
+		case "seq":
+			rec.seq, err = strconv.ParseUint(v, 10, 64)
+		case "len":
+			size, err = strconv.ParseInt(v, 10, 64)
+		case "prev":
+			rec.prev, err = hex.DecodeString(v)
+		case "mac":
+			rec.mac, err = hex.DecodeString(v)
+		}
+		if err != nil {
+			return rec, 0, fmt.Errorf("malformed audit header %q", line)
+		}
+	}
+	if rec.seq == 0 || size < 0 || len(rec.mac) != sha256.Size ||
+		(rec.prev != nil && len(rec.prev) != sha256.Size) {
+		return rec, 0, fmt.Errorf("malformed audit header %q", line)
+	}
+	return rec, size, nil
+}
@@ -0,0 +1,188 @@
+package logrus
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var auditKey = []byte("audit test key")
+
+// writeAuditFiles writes three hourly files of two records each and returns
+// their paths, oldest first.
+func writeAuditFiles(t *testing.T, cfg RotatingFileConfig) []string {
+	t.Helper()
+	clock := clocktest.NewFakeClock(testNow)
+	cfg.BaseName = "audit"
+	cfg.Clock = clock
+	w, err := NewAuditWriter(cfg, auditKey)
+	require.NoError(t, err)
+	logger := New()
+	logger.Out = w
+	logger.Formatter = &JSONFormatter{}
+	logger.Clock = clock
+	var paths []string
+	for i := 0; i < 3; i++ {
+		logger.WithField("file", i).Info("first")
+		logger.WithField("file", i).Info("second")
+		paths = append(paths, w.w.currentPath())
+		clock.Advance(time.Hour)
+	}
+	require.NoError(t, w.Close())
+	return paths
+}
+
+func TestAuditWriterVerifies(t *testing.T) {
+	dir := t.TempDir()
+	cfg := RotatingFileConfig{Dir: dir, Compress: &GzipCompressor{}}
+	writeAuditFiles(t, cfg)
+
+	// A restarted writer continues the chain.
+	cfg.BaseName = "audit"
+	cfg.Clock = clocktest.NewFakeClock(testNow.Add(3 * time.Hour))
+	w, err := NewAuditWriter(cfg, auditKey)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("after restart\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Verify())
+	require.NoError(t, w.Close())
+
+	files := w.w.listRotated()
+	assert.Len(t, files, 4)
+	for _, rf := range files[1:] {
+		assert.True(t, rf.compressed, rf.path)
+	}
+}
+
+func TestAuditWriterDetectsTampering(t *testing.T) {
+	paths := writeAuditFiles(t, RotatingFileConfig{Dir: t.TempDir()})
+	require.NoError(t, VerifyAuditLogs(auditKey, paths...))
+	// prev on its first record anchors a file whose predecessors are gone.
+	require.NoError(t, VerifyAuditLogs(auditKey, paths[1:]...))
+
+	assertFails := func(reason string, key []byte, paths ...string) {
+		t.Helper()
+		err := VerifyAuditLogs(key, paths...)
+		var auditErr *AuditError
+		require.ErrorAs(t, err, &auditErr)
+		assert.Contains(t, auditErr.Reason, reason)
+	}
+	assertFails("authentication failed", []byte("wrong key"), paths...)
+	assertFails("missing or reordered", auditKey, paths[1], paths[0], paths[2])
+	assertFails("missing or reordered", auditKey, paths[0], paths[2])
+
+	tamper := func(path string, edit func([]byte) []byte, check func()) {
+		t.Helper()
+		orig, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, edit(append([]byte(nil), orig...)), 0644))
+		check()
+		require.NoError(t, os.WriteFile(path, orig, 0644))
+	}
+	tamper(paths[1], func(b []byte) []byte {
+		return bytes.Replace(b, []byte("second"), []byte("sec0nd"), 1)
+	}, func() { assertFails("authentication failed", auditKey, paths...) })
+	tamper(paths[0], func(b []byte) []byte {
+		return b[:bytes.Index(b, []byte(auditManifestTag))]
+	}, func() { assertFails("not sealed", auditKey, paths...) })
+	tamper(paths[1], func(b []byte) []byte {
+		// Drop the second record and fix up nothing else.
+		lines := strings.SplitAfter(string(b), "\n")
+		return []byte(strings.Join(append(lines[:2:2], lines[4:]...), ""))
+	}, func() { assertFails("missing or reordered", auditKey, paths...) })
+	tamper(paths[2], func(b []byte) []byte {
+		return b[:len(b)-5]
+	}, func() { assertFails("truncated", auditKey, paths...) })
+	tamper(paths[2], func(b []byte) []byte {
+		// A length beyond the file must not be trusted to allocate.
+		return regexp.MustCompile(`len=\d+`).ReplaceAll(b, []byte("len=9223372036854775807"))
+	}, func() { assertFails("truncated", auditKey, paths...) })
+}
+
+func TestAuditWriterResumesAfterTornRecord(t *testing.T) {
+	crashed := t.TempDir()
+	cfg := RotatingFileConfig{Dir: crashed, BaseName: "audit", Clock: clocktest.NewFakeClock(testNow)}
+	w, err := NewAuditWriter(cfg, auditKey)
+	require.NoError(t, err)
+	for _, line := range []string{"one\n", "two\n"} {
+		_, err = w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	path := w.w.currentPath()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// The process died in the middle of its second record.
+	require.NoError(t, os.WriteFile(path, b[:len(b)-2], 0644))
+	w, err = NewAuditWriter(cfg, auditKey)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("three\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Verify())
+	require.NoError(t, w.Close())
+
+	b, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "one\n")
+	assert.NotContains(t, string(b), "tw")
+	assert.Contains(t, string(b), "three\n")
+}
+
+func TestAuditWriterFailedWrite(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewAuditWriter(RotatingFileConfig{Dir: t.TempDir(), BaseName: "audit", Clock: clock}, auditKey)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("one\n"))
+	require.NoError(t, err)
+
+	// The write fails, leaving part of a record behind.
+	w.w.mu.Lock()
+	require.NoError(t, w.w.file.Close())
+	path := w.w.path
+	w.w.mu.Unlock()
+	_, err = w.Write([]byte("lost\n"))
+	require.Error(t, err)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("#audit seq=3 len=")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	clock.Advance(time.Minute)
+	_, err = w.Write([]byte("two\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Verify())
+	require.NoError(t, w.Close())
+}
+
+func TestAuditWriterRecoversIntoNextPeriod(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	a, err := NewAuditWriter(RotatingFileConfig{
+		Dir:           t.TempDir(),
+		BaseName:      "audit",
+		Clock:         clock,
+		FailurePolicy: FailDrop,
+		RetryBackoff:  time.Hour,
+	}, auditKey)
+	require.NoError(t, err)
+	defer a.Close()
+	_, err = a.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	failWrites(t, a.w)
+	_, err = a.Write([]byte("dropped\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = a.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, a.Verify())
+}
@@ -0,0 +1,162 @@
+package logrus
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when a RotatingFileWriter fsyncs its file.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves flushing to disk to the operating system.
+	SyncNever SyncPolicy = iota
+	// SyncOnRotate syncs a file before it is closed by rotation or Close.
+	SyncOnRotate
+	// SyncPeriodic also syncs every RotatingFileConfig.SyncInterval.
+	SyncPeriodic
+	// SyncEveryWrite syncs after every Write, bypassing the buffer. Meant
+	// for audit files where no acknowledged line may be lost.
+	SyncEveryWrite
+)
+
+const (
+	defaultFlushInterval = time.Second
+	defaultSyncInterval  = time.Second
+)
+
+var (
+	// bufferedWriters are flushed by an exit handler before Fatal exits.
+	bufferedWritersMu   sync.Mutex
+	bufferedWriters     = map[*RotatingFileWriter]struct{}{}
+	bufferedWritersOnce sync.Once
+)
+
+func registerBufferedWriter(w *RotatingFileWriter) {
+	bufferedWritersOnce.Do(func() {
+		RegisterExitHandler(flushBufferedWriters)
+	})
+	bufferedWritersMu.Lock()
+	defer bufferedWritersMu.Unlock()
+	bufferedWriters[w] = struct{}{}
+}
+
+func unregisterBufferedWriter(w *RotatingFileWriter) {
+	bufferedWritersMu.Lock()
+	defer bufferedWritersMu.Unlock()
+	delete(bufferedWriters, w)
+}
+
+func flushBufferedWriters() {
+	bufferedWritersMu.Lock()
+	writers := make([]*RotatingFileWriter, 0, len(bufferedWriters))
+	for w := range bufferedWriters {
+		writers = append(writers, w)
+	}
+	bufferedWritersMu.Unlock()
+	for _, w := range writers {
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to flush log file: %v\n", err)
+		}
+	}
+}
+
+// writeOut sends p to the file, through the buffer when one is configured.
+// Callers hold w.mu and have an open file.
+func (w *RotatingFileWriter) writeOut(p []byte) (int, error) {
+	if w.bufSize == 0 || w.syncPolicy == SyncEveryWrite {
+		return w.writeFile(p)
+	}
+	if len(w.buf)+len(p) > w.bufSize {
+		if err := w.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) >= w.bufSize {
+		return w.writeFile(p)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// flushBuffer writes buffered data to the file. Whatever could not be
+// written stays buffered and goes to the next file that opens. Callers hold
+// w.mu.
+func (w *RotatingFileWriter) flushBuffer() error {
+	if len(w.buf) == 0 || w.file == nil {
+		return nil
+	}
+	n, err := w.writeFile(w.buf)
+	w.buf = w.buf[:copy(w.buf, w.buf[n:])]
+	return err
+}
+
+// closeFile flushes, syncs when the policy asks for it, and closes the
+// current file. Callers hold w.mu.
+func (w *RotatingFileWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.flushBuffer()
+	if err == nil && w.syncPolicy != SyncNever {
+		err = w.file.Sync()
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
+
+// Flush writes buffered data to the file.
+func (w *RotatingFileWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flushBuffer(); err != nil {
+		w.fail(w.clock.Now(), err)
+		return err
+	}
+	return nil
+}
+
+// Sync writes buffered data to the file and commits it to stable storage.
+func (w *RotatingFileWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.flushBuffer()
+	if err == nil && w.file != nil {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		w.fail(w.clock.Now(), err)
+	}
+	return err
+}
+
+// flushLoop flushes the buffer every FlushInterval and, under SyncPeriodic,
+// syncs every SyncInterval.
+func (w *RotatingFileWriter) flushLoop(flushEvery, syncEvery time.Duration) {
+	var flushTicks, syncTicks <-chan time.Time
+	if flushEvery > 0 {
+		ticks, stop := w.clock.NewTicker(flushEvery)
+		defer stop()
+		flushTicks = ticks
+	}
+	if syncEvery > 0 {
+		ticks, stop := w.clock.NewTicker(syncEvery)
+		defer stop()
+		syncTicks = ticks
+	}
+	for {
+		select {
+		case <-w.cleanupCh:
+			return
+		case <-flushTicks:
+			_ = w.Flush()
+		case <-syncTicks:
+			_ = w.Sync()
+		}
+	}
+}
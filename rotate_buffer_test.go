@@ -0,0 +1,129 @@
+package logrus
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestRotatingFileWriterBuffersUntilFlush(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        t.TempDir(),
+		BaseName:   "app",
+		Clock:      clock,
+		BufferSize: 64,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "", readString(t, w.currentPath()))
+
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "first\n", readString(t, w.currentPath()))
+
+	// A write that does not fit pushes out what is buffered and goes
+	// straight to the file.
+	big := make([]byte, 80)
+	for i := range big {
+		big[i] = 'x'
+	}
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	_, err = w.Write(big)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n"+string(big), readString(t, w.currentPath()))
+}
+
+func TestRotatingFileWriterFlushInterval(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:           t.TempDir(),
+		BaseName:      "app",
+		Clock:         clock,
+		BufferSize:    64,
+		FlushInterval: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+	// The cleanup loop and the flush loop.
+	require.Eventually(t, func() bool { return clock.Tickers() == 2 }, 5*time.Second, time.Millisecond)
+
+	_, err = w.Write([]byte("line\n"))
+	require.NoError(t, err)
+	clock.Advance(100 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		b, _ := os.ReadFile(w.currentPath())
+		return string(b) == "line\n"
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestRotatingFileWriterFlushesOnRotateAndClose(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        t.TempDir(),
+		BaseName:   "app",
+		Clock:      clock,
+		BufferSize: 64,
+		SyncPolicy: SyncOnRotate,
+	})
+	require.NoError(t, err)
+
+	first := w.currentPath()
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", readString(t, first))
+
+	second := w.currentPath()
+	require.NoError(t, w.Close())
+	assert.Equal(t, "after\n", readString(t, second))
+}
+
+func TestRotatingFileWriterFlushedBeforeExit(t *testing.T) {
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        t.TempDir(),
+		BaseName:   "app",
+		Clock:      clocktest.NewFakeClock(testNow),
+		BufferSize: 64,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("fatal\n"))
+	require.NoError(t, err)
+	runHandlers()
+	assert.Equal(t, "fatal\n", readString(t, w.currentPath()))
+}
+
+func TestRotatingFileWriterSyncEveryWrite(t *testing.T) {
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        t.TempDir(),
+		BaseName:   "app",
+		Clock:      clocktest.NewFakeClock(testNow),
+		BufferSize: 64,
+		SyncPolicy: SyncEveryWrite,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("audit\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "audit\n", readString(t, w.currentPath()))
+	require.NoError(t, w.Sync())
+}
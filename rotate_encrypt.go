@@ -0,0 +1,301 @@
+package logrus
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Encrypted files are a series of segments, one per time the file is
+// opened. A segment is a header
+//
+//	"LGENC1" len(keyID) keyID noncePrefix[8]
+//
+// followed by chunks, each 'c', a 4 byte big-endian length and an AES-GCM
+// sealed block. Every write that reaches the file is one chunk, split only
+// beyond maxEncryptedChunk, so with BufferSize set a chunk holds one flush of
+// the buffer; a crash tears at most the last chunk, which readers skip. The
+// nonce is the segment's random prefix and the chunk's index, so chunks
+// cannot be reordered or dropped without detection.
+var encryptMagic = []byte("LGENC1")
+
+const (
+	encryptChunkTag = 'c'
+	// maxEncryptedChunk bounds the sealed length of a chunk, so a reader
+	// never allocates more.
+	maxEncryptedChunk = 64 << 20
+)
+
+var (
+	// ErrCorruptEncryptedLog is returned when an encrypted log file fails
+	// authentication anywhere but in a chunk torn by a crash.
+	ErrCorruptEncryptedLog = errors.New("encrypted log file is corrupt or was tampered with")
+	errEncryptMultiProcess = errors.New("encrypted logs cannot be shared between processes")
+)
+
+// KeyProvider supplies AES keys of 16, 24 or 32 bytes for encrypting log
+// files. CurrentKey is asked for the key of each new segment; its ID is
+// stored in the file so readers can look the key up with KeyByID after the
+// current key has been rotated.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	KeyByID(id string) ([]byte, error)
+}
+
+// StaticKey is a KeyProvider holding a single key.
+type StaticKey struct {
+	ID  string
+	Key []byte
+}
+
+func (k *StaticKey) CurrentKey() (string, []byte, error) {
+	return k.ID, k.Key, nil
+}
+
+func (k *StaticKey) KeyByID(id string) ([]byte, error) {
+	if id != k.ID {
+		return nil, fmt.Errorf("unknown log encryption key %q", id)
+	}
+	return k.Key, nil
+}
+
+type chunkSealer struct {
+	aead   cipher.AEAD
+	prefix [8]byte
+	next   uint32
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// startSegment writes a segment header to the open file and resets the
+// chunk counter. Callers hold w.mu.
+func (w *RotatingFileWriter) startSegment() error {
+	id, key, err := w.keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("get log encryption key: %w", err)
+	}
+	if len(id) > math.MaxUint8 {
+		return fmt.Errorf("log encryption key id %q is too long", id)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return fmt.Errorf("log encryption key %q: %w", id, err)
+	}
+	s := &chunkSealer{aead: aead}
+	if _, err := rand.Read(s.prefix[:]); err != nil {
+		return err
+	}
+	header := append(append([]byte(nil), encryptMagic...), byte(len(id)))
+	header = append(append(header, id...), s.prefix[:]...)
+	n, err := w.file.Write(header)
+	w.fileSize += int64(n)
+	if err != nil {
+		return err
+	}
+	w.sealer = s
+	return nil
+}
+
+// writeFile writes p to the open file, sealing it in chunks when the writer
+// encrypts. Each chunk is all or nothing: on error only the chunks written
+// count. Records of an AuditWriter are written whole, see writeWhole.
+// Callers hold w.mu.
+func (w *RotatingFileWriter) writeFile(p []byte) (int, error) {
+	if w.keys == nil && w.frame != nil {
+		return w.writeWhole(p)
+	}
+	if w.keys == nil {
+		return w.file.Write(p)
+	}
+	written := 0
+	for len(p) > 0 {
+		if w.sealer == nil || w.sealer.next == math.MaxUint32 {
+			if err := w.startSegment(); err != nil {
+				return written, err
+			}
+		}
+		// Readers reject longer chunks.
+		size := len(p)
+		if limit := maxEncryptedChunk - w.sealer.aead.Overhead(); size > limit {
+			size = limit
+		}
+		if err := w.writeChunk(p[:size]); err != nil {
+			return written, err
+		}
+		written += size
+		p = p[size:]
+	}
+	return written, nil
+}
+
+// writeChunk seals p as one chunk. Callers hold w.mu.
+func (w *RotatingFileWriter) writeChunk(p []byte) error {
+	s := w.sealer
+	var nonce [12]byte
+	copy(nonce[:], s.prefix[:])
+	binary.BigEndian.PutUint32(nonce[8:], s.next)
+	s.next++
+	chunk := make([]byte, 5, 5+len(p)+s.aead.Overhead())
+	chunk[0] = encryptChunkTag
+	chunk = s.aead.Seal(chunk, nonce[:], p, nil)
+	binary.BigEndian.PutUint32(chunk[1:5], uint32(len(chunk)-5))
+	n, err := w.file.Write(chunk)
+	if err != nil {
+		w.fileSize += int64(n)
+		return err
+	}
+	// Callers count the plaintext.
+	w.fileSize += int64(len(chunk) - len(p))
+	return nil
+}
+
+// OpenEncryptedLog opens a log file written with RotatingFileConfig.Encrypt
+// and returns its plaintext. Files gzipped after rotation are decompressed
+// first.
+func OpenEncryptedLog(path string, keys KeyProvider) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r = zr
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{NewDecryptReader(r, keys), f}, nil
+}
+
+// NewDecryptReader returns the plaintext of an encrypted log stream. A chunk
+// torn by a crash, at the end of the stream or before the next segment, is
+// skipped; any other damage fails with ErrCorruptEncryptedLog.
+func NewDecryptReader(r io.Reader, keys KeyProvider) io.Reader {
+	return &decryptReader{r: bufio.NewReader(r), keys: keys}
+}
+
+type decryptReader struct {
+	r     *bufio.Reader
+	keys  KeyProvider
+	aead  cipher.AEAD
+	nonce [12]byte
+	next  uint32
+	buf   []byte
+	err   error
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 && d.err == nil {
+		d.err = d.readRecord()
+	}
+	if len(d.buf) > 0 {
+		n := copy(p, d.buf)
+		d.buf = d.buf[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+func (d *decryptReader) readRecord() error {
+	tag, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case tag == encryptMagic[0]:
+		return d.readHeader()
+	case tag == encryptChunkTag && d.aead != nil:
+		return d.readChunk()
+	default:
+		return ErrCorruptEncryptedLog
+	}
+}
+
+func (d *decryptReader) readHeader() error {
+	magic := make([]byte, len(encryptMagic)-1)
+	if _, err := io.ReadFull(d.r, magic); err != nil || !bytes.Equal(magic, encryptMagic[1:]) {
+		return ErrCorruptEncryptedLog
+	}
+	idLen, err := d.r.ReadByte()
+	if err != nil {
+		return ErrCorruptEncryptedLog
+	}
+	rest := make([]byte, int(idLen)+8)
+	if _, err := io.ReadFull(d.r, rest); err != nil {
+		return ErrCorruptEncryptedLog
+	}
+	id := string(rest[:idLen])
+	key, err := d.keys.KeyByID(id)
+	if err != nil {
+		return err
+	}
+	if d.aead, err = newGCM(key); err != nil {
+		return fmt.Errorf("log encryption key %q: %w", id, err)
+	}
+	copy(d.nonce[:8], rest[idLen:])
+	d.next = 0
+	return nil
+}
+
+func (d *decryptReader) readChunk() error {
+	var size [4]byte
+	n, err := io.ReadFull(d.r, size[:])
+	if err != nil {
+		return d.torn(size[:n], err)
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxEncryptedChunk {
+		return d.torn(size[:], nil)
+	}
+	body := make([]byte, length)
+	n, err = io.ReadFull(d.r, body)
+	if err != nil {
+		return d.torn(append(size[:], body[:n]...), err)
+	}
+	binary.BigEndian.PutUint32(d.nonce[8:], d.next)
+	plain, err := d.aead.Open(body[:0], d.nonce[:], body, nil)
+	if err != nil {
+		return d.torn(append(size[:], body...), nil)
+	}
+	d.next++
+	d.buf = plain
+	return nil
+}
+
+// torn handles a chunk that did not decode. If a new segment starts inside
+// the bytes read for it, the chunk was cut short by a crash and reading
+// resumes there; a short chunk at the end of the stream is dropped.
+func (d *decryptReader) torn(consumed []byte, readErr error) error {
+	// The magic may straddle the end of what was consumed.
+	peek, _ := d.r.Peek(len(encryptMagic))
+	window := append(append([]byte(nil), consumed...), peek...)
+	if i := bytes.Index(window, encryptMagic); i >= 0 && i < len(consumed) {
+		d.r = bufio.NewReader(io.MultiReader(bytes.NewReader(consumed[i:]), d.r))
+		return nil
+	}
+	if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return ErrCorruptEncryptedLog
+}
@@ -0,0 +1,185 @@
+package logrus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = &StaticKey{ID: "k1", Key: bytes.Repeat([]byte{7}, 32)}
+
+func readEncrypted(t *testing.T, path string, keys KeyProvider) (string, error) {
+	t.Helper()
+	rc, err := OpenEncryptedLog(path, keys)
+	require.NoError(t, err)
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	return string(b), err
+}
+
+func TestRotatingFileWriterEncrypts(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	rotated := make(chan string, 1)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        t.TempDir(),
+		BaseName:   "app",
+		Clock:      clock,
+		Encrypt:    testKey,
+		BufferSize: 64,
+		Compress:   &GzipCompressor{},
+		OnRotate:   func(oldPath, newPath string) { rotated <- oldPath },
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	var want string
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("secret line %d\n", i)
+		want += line
+		_, err = w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Flush())
+	raw, err := os.ReadFile(w.currentPath())
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("next\n"))
+	require.NoError(t, err)
+	path := <-rotated
+	got, err := readEncrypted(t, path, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestRotatingFileWriterEncryptsLargeWrite(t *testing.T) {
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      t.TempDir(),
+		BaseName: "app",
+		Clock:    clocktest.NewFakeClock(testNow),
+		Encrypt:  testKey,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	// Larger than a reader accepts in one chunk.
+	big := bytes.Repeat([]byte("0123456789abcdef"), (maxEncryptedChunk+1<<20)/16)
+	n, err := w.Write(big)
+	require.NoError(t, err)
+	assert.Equal(t, len(big), n)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	got, err := readEncrypted(t, w.currentPath(), testKey)
+	require.NoError(t, err)
+	require.Equal(t, len(big)+len("after\n"), len(got))
+	assert.True(t, got[:len(big)] == string(big))
+	assert.Equal(t, "after\n", got[len(big):])
+}
+
+func TestRotatingFileWriterEncryptionSurvivesCrash(t *testing.T) {
+	dir := t.TempDir()
+	cfg := RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clocktest.NewFakeClock(testNow), Encrypt: testKey}
+	w, err := NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	for _, line := range []string{"one\n", "two\n", "torn\n"} {
+		_, err = w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	path := w.currentPath()
+	require.NoError(t, w.Close())
+
+	// Cut the last chunk short, as a crash mid-write would.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+	got, err := readEncrypted(t, path, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", got)
+
+	// A restarted writer appends a new segment after the torn chunk.
+	w, err = NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("three\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	got, err = readEncrypted(t, path, testKey)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\nthree\n", got)
+}
+
+func TestRotatingFileWriterEncryptionDetectsTampering(t *testing.T) {
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      t.TempDir(),
+		BaseName: "app",
+		Clock:    clocktest.NewFakeClock(testNow),
+		Encrypt:  testKey,
+	})
+	require.NoError(t, err)
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		_, err = w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	path := w.currentPath()
+	require.NoError(t, w.Close())
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	raw[len(raw)/2] ^= 1
+	require.NoError(t, os.WriteFile(path, raw, 0644))
+	_, err = readEncrypted(t, path, testKey)
+	assert.ErrorIs(t, err, ErrCorruptEncryptedLog)
+}
+
+type rotatingKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k *rotatingKeys) CurrentKey() (string, []byte, error) {
+	return k.current, k.keys[k.current], nil
+}
+
+func (k *rotatingKeys) KeyByID(id string) ([]byte, error) {
+	if key, ok := k.keys[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", id)
+}
+
+func TestRotatingFileWriterEncryptionKeyRotation(t *testing.T) {
+	keys := &rotatingKeys{current: "old", keys: map[string][]byte{
+		"old": bytes.Repeat([]byte{1}, 16),
+		"new": bytes.Repeat([]byte{2}, 16),
+	}}
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      t.TempDir(),
+		BaseName: "app",
+		Clock:    clocktest.NewFakeClock(testNow),
+		Encrypt:  keys,
+	})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("under old\n"))
+	require.NoError(t, err)
+	keys.current = "new"
+	// The key is picked per segment, so the new key applies once reopened.
+	require.NoError(t, w.Reopen())
+	_, err = w.Write([]byte("under new\n"))
+	require.NoError(t, err)
+	path := w.currentPath()
+	require.NoError(t, w.Close())
+
+	got, err := readEncrypted(t, path, keys)
+	require.NoError(t, err)
+	assert.Equal(t, "under old\nunder new\n", got)
+	_, err = readEncrypted(t, path, &StaticKey{ID: "new", Key: keys.keys["new"]})
+	assert.Error(t, err)
+}
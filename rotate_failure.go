@@ -0,0 +1,130 @@
+package logrus
+
+import "time"
+
+// FailurePolicy decides what RotatingFileWriter.Write does while the log
+// file cannot be opened or written, e.g. because the disk is full or the
+// directory lost its permissions. Whatever the policy, the writer keeps
+// trying to reopen the file, backing off exponentially between attempts,
+// and resumes normal writing as soon as that succeeds.
+type FailurePolicy int
+
+const (
+	// FailReturnError returns the error from Write. This is the default.
+	FailReturnError FailurePolicy = iota
+	// FailFallback sends the data to RotatingFileConfig.Fallback instead.
+	FailFallback
+	// FailDrop discards the data and only counts it.
+	FailDrop
+)
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultMaxRetryBackoff = time.Minute
+)
+
+// WriterHealth is a snapshot of a RotatingFileWriter's state, see Health.
+type WriterHealth struct {
+	// Healthy is false from the first failure until the file is writable
+	// again.
+	Healthy bool
+	// LastError is the most recent open or write error, kept after
+	// recovery; Since is when the current failure began.
+	LastError error
+	Since     time.Time
+	// NextRetry is when the writer will next try to reopen the file.
+	NextRetry time.Time
+	// Failures counts failed writes; FallbackWrites and Dropped count those
+	// the policy sent to the fallback writer or discarded, DroppedBytes
+	// their size.
+	Failures       uint64
+	FallbackWrites uint64
+	Dropped        uint64
+	DroppedBytes   uint64
+}
+
+// Health reports whether the writer currently writes to its file, and how
+// many writes went elsewhere or were lost.
+func (w *RotatingFileWriter) Health() WriterHealth {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.health
+}
+
+// fail records err, closes the current file and schedules the next reopen
+// attempt. Callers hold w.mu.
+func (w *RotatingFileWriter) fail(now time.Time, err error) {
+	if w.health.Healthy {
+		w.health.Healthy = false
+		w.health.Since = now
+		w.backoff = w.minBackoff
+	} else if w.backoff *= 2; w.backoff > w.maxBackoff {
+		w.backoff = w.maxBackoff
+	}
+	w.health.LastError = err
+	w.health.NextRetry = now.Add(w.backoff)
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+}
+
+// ensureFile makes sure a file for now is open, rotating or, after a
+// failure, reopening it once the backoff has passed. Callers hold w.mu.
+func (w *RotatingFileWriter) ensureFile(now time.Time) error {
+	if w.file == nil {
+		if now.Before(w.health.NextRetry) {
+			return w.health.LastError
+		}
+		if err := w.reopen(now); err != nil {
+			w.fail(now, err)
+			return err
+		}
+		w.health.Healthy = true
+		return nil
+	}
+	if err := w.rotateIfNeeded(now); err != nil {
+		w.fail(now, err)
+		return err
+	}
+	return nil
+}
+
+// writeFailed applies the failure policy to p. Callers hold w.mu.
+func (w *RotatingFileWriter) writeFailed(p []byte, err error) (int, error) {
+	w.health.Failures++
+	switch w.policy {
+	case FailFallback:
+		w.health.FallbackWrites++
+		return w.fallback.Write(p)
+	case FailDrop:
+		w.health.Dropped++
+		w.health.DroppedBytes += uint64(len(p))
+		return len(p), nil
+	default:
+		return 0, err
+	}
+}
+
+// reopen opens a file again after a failure. The file abandoned by fail is
+// reopened first, so that if the writer has to move on it is sealed and
+// finished like any rotated file. If it cannot be, it is finished as it is.
+// Callers hold w.mu.
+func (w *RotatingFileWriter) reopen(now time.Time) error {
+	old := w.path
+	if old != "" {
+		unlock := w.lockDir()
+		err := w.openPeriod(w.openedAt, w.seq)
+		unlock()
+		if err == nil {
+			return w.rotateIfNeeded(now)
+		}
+	}
+	if err := w.openFile(now); err != nil {
+		return err
+	}
+	if old != "" && old != w.path {
+		w.finish(old, w.path)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package logrus
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// breakDir makes the next rotation fail by removing the log directory.
+func breakDir(t *testing.T, dir string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("open files cannot be removed on windows")
+	}
+	require.NoError(t, os.RemoveAll(dir))
+}
+
+func TestRotatingFileWriterFallbackAndRecovery(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	var fallback bytes.Buffer
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:           dir,
+		BaseName:      "app",
+		Clock:         clock,
+		FailurePolicy: FailFallback,
+		Fallback:      &fallback,
+		RetryBackoff:  time.Second,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	breakDir(t, dir)
+	clock.Advance(time.Hour)
+	n, err := w.Write([]byte("lost file\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+	health := w.Health()
+	assert.False(t, health.Healthy)
+	assert.True(t, errors.Is(health.LastError, os.ErrNotExist), health.LastError)
+	assert.Equal(t, clock.Now(), health.Since)
+
+	// Within the backoff the writer does not even try to reopen.
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	_, err = w.Write([]byte("still backing off\n"))
+	require.NoError(t, err)
+	assert.False(t, w.Health().Healthy)
+
+	clock.Advance(time.Second)
+	_, err = w.Write([]byte("recovered\n"))
+	require.NoError(t, err)
+	health = w.Health()
+	assert.True(t, health.Healthy)
+	assert.Equal(t, uint64(2), health.FallbackWrites)
+	assert.Equal(t, "lost file\nstill backing off\n", fallback.String())
+
+	b, err := os.ReadFile(w.currentPath())
+	require.NoError(t, err)
+	assert.Equal(t, "recovered\n", string(b))
+}
+
+func TestRotatingFileWriterDropPolicy(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:             dir,
+		BaseName:        "app",
+		Clock:           clock,
+		FailurePolicy:   FailDrop,
+		RetryBackoff:    time.Second,
+		MaxRetryBackoff: 4 * time.Second,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	breakDir(t, dir)
+	clock.Advance(time.Hour)
+	for i := 0; i < 4; i++ {
+		_, err = w.Write([]byte("dropped"))
+		require.NoError(t, err)
+		clock.Advance(w.Health().NextRetry.Sub(clock.Now()))
+	}
+
+	health := w.Health()
+	assert.Equal(t, uint64(4), health.Dropped)
+	assert.Equal(t, uint64(28), health.DroppedBytes)
+	assert.Equal(t, 4*time.Second, w.backoff, "backoff doubles up to the maximum")
+}
+
+func TestRotatingFileWriterReturnErrorPolicy(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clock})
+	require.NoError(t, err)
+	defer w.Close()
+
+	breakDir(t, dir)
+	clock.Advance(time.Hour)
+	n, err := w.Write([]byte("x"))
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, uint64(1), w.Health().Failures)
+}
+
+// failWrites makes the next write to the current file fail, as on a full
+// disk.
+func failWrites(t *testing.T, w *RotatingFileWriter) {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	require.NoError(t, w.file.Close())
+}
+
+func TestRotatingFileWriterRecoversIntoNextPeriod(t *testing.T) {
+	clock := clocktest.NewFakeClock(testNow)
+	rotated := make(chan [2]string, 1)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:           t.TempDir(),
+		BaseName:      "app",
+		Clock:         clock,
+		Compress:      &GzipCompressor{},
+		FailurePolicy: FailDrop,
+		RetryBackoff:  time.Hour,
+		OnRotate:      func(oldPath, newPath string) { rotated <- [2]string{oldPath, newPath} },
+	})
+	require.NoError(t, err)
+	defer w.Close()
+	first := w.currentPath()
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	failWrites(t, w)
+	_, err = w.Write([]byte("dropped\n"))
+	require.NoError(t, err)
+	assert.False(t, w.Health().Healthy)
+
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	assert.True(t, w.Health().Healthy)
+	second := w.currentPath()
+	require.NotEqual(t, first, second)
+
+	select {
+	case r := <-rotated:
+		assert.Equal(t, [2]string{first + ".gz", second}, r)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the abandoned file was never finished")
+	}
+	assert.Equal(t, "before\n", readGzip(t, first+".gz"))
+}
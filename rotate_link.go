@@ -0,0 +1,105 @@
+package logrus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LinkMode decides how RotatingFileConfig.LinkName follows the current file.
+type LinkMode int
+
+const (
+	// LinkSymlink makes LinkName a symbolic link to the current file.
+	LinkSymlink LinkMode = iota
+	// LinkHardlink makes LinkName a hard link to the current file, for tools
+	// that do not follow symlinks.
+	LinkHardlink
+	// LinkCurrentFile writes to LinkName itself and renames it to its
+	// timestamped name when it rotates, so readers always find the live file
+	// under one name. It cannot be combined with MultiProcess.
+	LinkCurrentFile
+)
+
+var errCurrentFileMultiProcess = errors.New("LinkCurrentFile cannot be combined with MultiProcess")
+
+func (w *RotatingFileWriter) linkPath() string {
+	return filepath.Join(w.dir, w.linkName)
+}
+
+// updateLink points LinkName at path. The new link is made under a
+// temporary name and renamed over the old one, so readers never find the
+// link missing. Callers hold lockDir.
+func (w *RotatingFileWriter) updateLink(path string) error {
+	linkPath := w.linkPath()
+	tmp := filepath.Join(filepath.Dir(linkPath), "."+filepath.Base(linkPath)+".tmp")
+	_ = os.Remove(tmp)
+	var err error
+	switch w.linkMode {
+	case LinkHardlink:
+		err = os.Link(path, tmp)
+	default:
+		target := path
+		if w.linkAbsolute {
+			target, err = filepath.Abs(path)
+		} else {
+			target, err = filepath.Rel(filepath.Dir(linkPath), path)
+		}
+		if err == nil {
+			err = os.Symlink(target, tmp)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("create link %s: %w", linkPath, err)
+	}
+	if w.owner != nil {
+		_ = os.Lchown(tmp, w.owner.UID, w.owner.GID)
+	}
+	err = os.Rename(tmp, linkPath)
+	// Renaming a hard link onto another link to the same file succeeds
+	// without removing the source.
+	_ = os.Remove(tmp)
+	if err != nil {
+		return fmt.Errorf("update link %s: %w", linkPath, err)
+	}
+	return nil
+}
+
+// archiveCurrent renames the LinkCurrentFile file, written during period, to
+// the first unused sequence number of that period and returns the new path.
+// Callers hold lockDir and have closed the file.
+func (w *RotatingFileWriter) archiveCurrent(period time.Time) (string, error) {
+	seq := 0
+	for _, rf := range w.listRotated() {
+		if rf.time.Equal(period) && rf.seq >= seq {
+			seq = rf.seq + 1
+		}
+	}
+	path := w.rotatedFileName(period, seq)
+	if err := os.Rename(w.linkPath(), path); err != nil {
+		return "", fmt.Errorf("archive log file %s: %w", w.linkPath(), err)
+	}
+	return path, nil
+}
+
+// archiveStale archives a LinkCurrentFile file left by an earlier process if
+// it belongs to a period other than the one starting now. Its period comes
+// from its modification time. Callers hold lockDir.
+func (w *RotatingFileWriter) archiveStale(period time.Time) error {
+	info, err := os.Stat(w.linkPath())
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	last := w.periodStart(info.ModTime())
+	if last.Equal(period) {
+		return nil
+	}
+	path, err := w.archiveCurrent(last)
+	if err != nil {
+		return err
+	}
+	w.finish(path, w.linkPath())
+	return nil
+}
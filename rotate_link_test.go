@@ -0,0 +1,132 @@
+package logrus
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileWriterSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	for _, absolute := range []bool{false, true} {
+		dir := t.TempDir()
+		clock := clocktest.NewFakeClock(testNow)
+		w, err := NewRotatingFileWriter(RotatingFileConfig{
+			Dir:          dir,
+			BaseName:     "app",
+			LinkName:     "app.log",
+			LinkAbsolute: absolute,
+			Clock:        clock,
+		})
+		require.NoError(t, err)
+
+		link := filepath.Join(dir, "app.log")
+		for i := 0; i < 2; i++ {
+			target, err := os.Readlink(link)
+			require.NoError(t, err)
+			want := filepath.Base(w.currentPath())
+			if absolute {
+				want, err = filepath.Abs(w.currentPath())
+				require.NoError(t, err)
+			}
+			assert.Equal(t, want, target)
+			clock.Advance(time.Hour)
+			_, err = w.Write([]byte("x\n"))
+			require.NoError(t, err)
+		}
+		assert.False(t, exists(filepath.Join(dir, ".app.log.tmp")))
+		require.NoError(t, w.Close())
+	}
+}
+
+func TestRotatingFileWriterHardlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the name of an open file cannot be replaced on windows")
+	}
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		LinkName: "app.log",
+		LinkMode: LinkHardlink,
+		Clock:    clock,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	link := filepath.Join(dir, "app.log")
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte("x\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Reopen())
+		linkInfo, err := os.Stat(link)
+		require.NoError(t, err)
+		currentInfo, err := os.Stat(w.currentPath())
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(linkInfo, currentInfo))
+		clock.Advance(time.Hour)
+	}
+	// The link is never mistaken for a rotated file.
+	assert.Len(t, w.listRotated(), 3)
+	assert.False(t, exists(filepath.Join(dir, ".app.log.tmp")))
+}
+
+func TestRotatingFileWriterCurrentFile(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	cfg := RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		LinkName: "app.log",
+		LinkMode: LinkCurrentFile,
+		Clock:    clock,
+	}
+	w, err := NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+
+	current := filepath.Join(dir, "app.log")
+	assert.Equal(t, current, w.currentPath())
+	_, err = w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Rotate())
+	_, err = w.Write([]byte("third\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	first := time.Date(2026, 10, 16, 15, 0, 0, 0, time.Local)
+	assert.Equal(t, "first\n", readString(t, w.rotatedFileName(first, 0)))
+	assert.Equal(t, "second\n", readString(t, w.rotatedFileName(first.Add(time.Hour), 0)))
+	assert.Equal(t, "third\n", readString(t, current))
+
+	// A file left from an earlier period is archived on startup.
+	require.NoError(t, os.Chtimes(current, clock.Now(), clock.Now()))
+	clock.Advance(time.Hour)
+	w, err = NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, "third\n", readString(t, w.rotatedFileName(first.Add(time.Hour), 1)))
+	assert.Equal(t, "", readString(t, current))
+}
+
+func TestRotatingFileWriterCurrentFileNotMultiProcess(t *testing.T) {
+	_, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:          t.TempDir(),
+		BaseName:     "app",
+		LinkName:     "app.log",
+		LinkMode:     LinkCurrentFile,
+		MultiProcess: true,
+	})
+	assert.ErrorIs(t, err, errCurrentFileMultiProcess)
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+
+package logrus
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const flockSupported = true
+
+func lockExclusive(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
+
+// tryLockShared fails with errFileBusy while another open file description
+// holds an exclusive lock on f.
+func tryLockShared(f *os.File) error {
+	return tryFlock(f, unix.LOCK_SH)
+}
+
+// tryLockExclusive fails with errFileBusy while any other open file
+// description holds a lock on f.
+func tryLockExclusive(f *os.File) error {
+	return tryFlock(f, unix.LOCK_EX)
+}
+
+func tryFlock(f *os.File, how int) error {
+	err := unix.Flock(int(f.Fd()), how|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return errFileBusy
+	}
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
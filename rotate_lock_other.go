@@ -0,0 +1,23 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris)
+
+package logrus
+
+import "os"
+
+const flockSupported = false
+
+func lockExclusive(f *os.File) error {
+	return errFlockUnsupported
+}
+
+func tryLockShared(f *os.File) error {
+	return errFlockUnsupported
+}
+
+func tryLockExclusive(f *os.File) error {
+	return errFlockUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errFlockUnsupported
+}
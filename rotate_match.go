@@ -0,0 +1,152 @@
+package logrus
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rotatedTimeLayout is the default timestamp embedded in rotated file names.
+const rotatedTimeLayout = "200601021504"
+
+// rotatedFile is a file recognised as belonging to a RotatingFileWriter.
+type rotatedFile struct {
+	path string
+	// time is the start of the period, parsed from the file name.
+	time time.Time
+	// seq numbers size rotations within one period; the first file is 0.
+	seq  int
+	size int64
+	// compressed is set for names carrying a compression suffix.
+	compressed bool
+	// partial marks an interrupted compression (".tmp" suffix).
+	partial bool
+	// archived is set for files found under ArchiveDir.
+	archived bool
+}
+
+// parseRotatedName reports whether name is exactly
+//
+//	baseName "." timestamp ["." seq] ext [compression suffix] [".tmp"]
+//
+// Anything else, such as the files of a writer whose base name merely starts
+// with ours or the current-file link, is rejected.
+func (w *RotatingFileWriter) parseRotatedName(name string) (rotatedFile, bool) {
+	var rf rotatedFile
+	prefix := w.baseName + "."
+	if !strings.HasPrefix(name, prefix) {
+		return rf, false
+	}
+	rest := name[len(prefix):]
+	if strings.HasSuffix(rest, ".tmp") {
+		rest = strings.TrimSuffix(rest, ".tmp")
+		rf.partial = true
+	}
+	if !strings.HasSuffix(rest, w.ext) {
+		suffix := filepath.Ext(rest)
+		if !isCompressionSuffix(suffix) {
+			return rf, false
+		}
+		rest = strings.TrimSuffix(rest, suffix)
+		rf.compressed = true
+	}
+	if !strings.HasSuffix(rest, w.ext) {
+		return rf, false
+	}
+	stamp := strings.TrimSuffix(rest, w.ext)
+	// The layout may itself contain dots, so try the stamp as a whole before
+	// splitting off a sequence number.
+	t, err := time.ParseInLocation(w.layout, stamp, w.loc)
+	if err != nil {
+		i := strings.LastIndexByte(stamp, '.')
+		if i < 0 {
+			return rf, false
+		}
+		seq, err := strconv.Atoi(stamp[i+1:])
+		if err != nil || seq <= 0 || strconv.Itoa(seq) != stamp[i+1:] {
+			return rf, false
+		}
+		if t, err = time.ParseInLocation(w.layout, stamp[:i], w.loc); err != nil {
+			return rf, false
+		}
+		rf.seq = seq
+		stamp = stamp[:i]
+	}
+	// Parsing is lenient about digits, so insist the stamp is canonical.
+	if t.Format(w.layout) != stamp {
+		return rf, false
+	}
+	rf.time = t
+	return rf, true
+}
+
+// isCompressionSuffix accepts a short alphanumeric extension such as ".gz"
+// or ".zst", so files compressed under an earlier configuration still count.
+func isCompressionSuffix(s string) bool {
+	if len(s) < 2 || len(s) > 5 || s[0] != '.' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// listRotated returns the files of this writer in w.dir and the ArchiveDir
+// tree, newest first. The current file is included; callers skip it by path.
+func (w *RotatingFileWriter) listRotated() []rotatedFile {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil
+	}
+	var files []rotatedFile
+	for _, entry := range entries {
+		if rf, ok := w.rotatedEntry(w.dir, entry); ok {
+			files = append(files, rf)
+		}
+	}
+	if w.archiveDir != "" {
+		dir := filepath.Clean(w.dir)
+		_ = filepath.WalkDir(w.archiveDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || filepath.Dir(path) == dir {
+				return nil
+			}
+			if rf, ok := w.rotatedEntry(filepath.Dir(path), entry); ok {
+				rf.archived = true
+				files = append(files, rf)
+			}
+			return nil
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].time.Equal(files[j].time) {
+			return files[i].time.After(files[j].time)
+		}
+		return files[i].seq > files[j].seq
+	})
+	return files
+}
+
+func (w *RotatingFileWriter) rotatedEntry(dir string, entry fs.DirEntry) (rotatedFile, bool) {
+	// A hard link or LinkCurrentFile file may look like ours.
+	if !entry.Type().IsRegular() || entry.Name() == w.linkName {
+		return rotatedFile{}, false
+	}
+	rf, ok := w.parseRotatedName(entry.Name())
+	if !ok {
+		return rf, false
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return rf, false
+	}
+	rf.path = filepath.Join(dir, entry.Name())
+	rf.size = info.Size()
+	return rf, true
+}
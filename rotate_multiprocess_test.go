@@ -0,0 +1,114 @@
+package logrus
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Two writers in one process stand in for two processes: flock locks belong
+// to open file descriptions, not to processes.
+func newMultiProcessPair(t *testing.T, cfg RotatingFileConfig) (*RotatingFileWriter, *RotatingFileWriter) {
+	t.Helper()
+	if !flockSupported {
+		t.Skip("flock is not supported on this platform")
+	}
+	cfg.Dir = t.TempDir()
+	cfg.BaseName = "app"
+	cfg.MultiProcess = true
+	if cfg.Clock == nil {
+		cfg.Clock = clocktest.NewFakeClock(testNow)
+	}
+	a, err := NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	b, err := NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	return a, b
+}
+
+func TestMultiProcessCompressionWaitsForOtherWriters(t *testing.T) {
+	a, b := newMultiProcessPair(t, RotatingFileConfig{Compress: &GzipCompressor{}})
+	shared := a.currentPath()
+	require.Equal(t, shared, b.currentPath())
+
+	_, err := a.Write([]byte("from a\n"))
+	require.NoError(t, err)
+	require.NoError(t, a.Rotate())
+	require.NoError(t, a.Close())
+
+	// b still has the file open, so a must leave it alone.
+	assert.True(t, exists(shared))
+	assert.False(t, exists(shared+".gz"))
+	_, err = b.Write([]byte("from b\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, b.Rotate())
+	require.NoError(t, b.Close())
+	assert.False(t, exists(shared))
+	assert.Equal(t, "from a\nfrom b\n", readGzip(t, shared+".gz"))
+}
+
+func TestMultiProcessCleanupSkipsOpenFiles(t *testing.T) {
+	a, b := newMultiProcessPair(t, RotatingFileConfig{})
+	defer a.Close()
+	defer b.Close()
+	require.NoError(t, b.Rotate())
+	held := b.currentPath()
+	require.NotEqual(t, held, a.currentPath())
+
+	// Everything is past the cutoff, but both current files are in use.
+	a.removeOldFiles(testNow.Add(time.Hour))
+
+	assert.True(t, exists(held))
+	assert.True(t, exists(a.currentPath()))
+}
+
+func TestMultiProcessSizeRotationConverges(t *testing.T) {
+	a, b := newMultiProcessPair(t, RotatingFileConfig{MaxSize: 10})
+	defer a.Close()
+	defer b.Close()
+
+	_, err := a.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	// b has not written anything itself, but sees the shared file is full.
+	_, err = b.Write([]byte("b"))
+	require.NoError(t, err)
+	_, err = a.Write([]byte("a"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, b.seq)
+	assert.Equal(t, b.currentPath(), a.currentPath())
+	content, err := os.ReadFile(a.currentPath())
+	require.NoError(t, err)
+	assert.Equal(t, "ba", string(content))
+}
+
+func TestMultiProcessRotateSkipsFinishedSequences(t *testing.T) {
+	a, b := newMultiProcessPair(t, RotatingFileConfig{Compress: &GzipCompressor{}})
+	defer b.Close()
+
+	require.NoError(t, b.Rotate())
+	finished := b.currentPath()
+	_, err := b.Write([]byte("b1 important\n"))
+	require.NoError(t, err)
+	require.NoError(t, b.Rotate())
+	require.Eventually(t, func() bool { return exists(finished + ".gz") }, 5*time.Second, 10*time.Millisecond)
+
+	// a is still on the first file and must not reuse b's numbers.
+	require.NoError(t, a.Rotate())
+	assert.Equal(t, b.currentPath(), a.currentPath())
+	_, err = a.Write([]byte("a1\n"))
+	require.NoError(t, err)
+
+	// Nor when reopening after b has moved on again.
+	require.NoError(t, b.Rotate())
+	require.NoError(t, a.Reopen())
+	assert.Equal(t, b.currentPath(), a.currentPath())
+	require.NoError(t, a.Close())
+
+	assert.Equal(t, "b1 important\n", readGzip(t, finished+".gz"))
+}
@@ -0,0 +1,68 @@
+package logrus
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	defaultFileMode os.FileMode = 0666
+	defaultDirMode  os.FileMode = 0755
+)
+
+// FileOwner is the owner given to log files. Either id may be -1 to leave it
+// unchanged, as with os.Chown. Changing ownership usually needs privileges
+// and is not supported on windows or plan9.
+type FileOwner struct {
+	UID int
+	GID int
+}
+
+// createMode is the mode passed to open(2); the umask still applies to it.
+func (w *RotatingFileWriter) createMode() os.FileMode {
+	if w.fileMode != 0 {
+		return w.fileMode
+	}
+	return defaultFileMode
+}
+
+// applyFilePerm gives a log file or archive the configured mode and owner.
+// An explicit FileMode is enforced exactly, regardless of the umask.
+func (w *RotatingFileWriter) applyFilePerm(f *os.File, info os.FileInfo) error {
+	if w.fileMode != 0 && (info == nil || info.Mode().Perm() != w.fileMode.Perm()) {
+		if err := f.Chmod(w.fileMode); err != nil {
+			return fmt.Errorf("chmod %s: %w", f.Name(), err)
+		}
+	}
+	if w.owner != nil {
+		if err := f.Chown(w.owner.UID, w.owner.GID); err != nil {
+			return fmt.Errorf("chown %s: %w", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+// makeDir creates a log or archive directory. If it did not exist and
+// DirMode or Owner was set, they are applied to it exactly; parents created
+// on the way only get DirMode minus the umask.
+func (w *RotatingFileWriter) makeDir(dir string) error {
+	_, err := os.Stat(dir)
+	created := os.IsNotExist(err)
+	if err := os.MkdirAll(dir, w.dirMode); err != nil {
+		return fmt.Errorf("create log directory %s: %w", dir, err)
+	}
+	if !created {
+		return nil
+	}
+	if w.exactDirMode {
+		if err := os.Chmod(dir, w.dirMode); err != nil {
+			return fmt.Errorf("chmod log directory %s: %w", dir, err)
+		}
+	}
+	if w.owner != nil {
+		if err := os.Chown(dir, w.owner.UID, w.owner.GID); err != nil {
+			return fmt.Errorf("chown log directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
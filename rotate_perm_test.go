@@ -0,0 +1,78 @@
+package logrus
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requirePerm(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, info.Mode().Perm(), path)
+}
+
+func TestRotatingFileWriterFileAndDirMode(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("unix permissions are not supported on " + runtime.GOOS)
+	}
+	dir := filepath.Join(t.TempDir(), "logs")
+	clock := clocktest.NewFakeClock(testNow)
+	rotated := make(chan string, 1)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		LinkName: "app.log",
+		Clock:    clock,
+		Compress: &GzipCompressor{},
+		FileMode: 0600,
+		DirMode:  0750,
+		Owner:    &FileOwner{UID: os.Getuid(), GID: os.Getgid()},
+		OnRotate: func(oldPath, newPath string) { rotated <- oldPath },
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	requirePerm(t, dir, 0750)
+	requirePerm(t, w.currentPath(), 0600)
+	requirePerm(t, filepath.Join(dir, "app.log"), 0600)
+
+	_, err = w.Write([]byte("line\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("next\n"))
+	require.NoError(t, err)
+	select {
+	case archive := <-rotated:
+		requirePerm(t, archive, 0600)
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotated file was not compressed")
+	}
+	requirePerm(t, w.currentPath(), 0600)
+}
+
+func TestRotatingFileWriterFileModeFixesExistingFile(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("unix permissions are not supported on " + runtime.GOOS)
+	}
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clock})
+	require.NoError(t, err)
+	path := w.currentPath()
+	require.NoError(t, w.Close())
+	require.NoError(t, os.Chmod(path, 0644))
+
+	w, err = NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clock, FileMode: 0640})
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, path, w.currentPath())
+	requirePerm(t, path, 0640)
+}
@@ -1,10 +1,13 @@
 package logrus
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,29 +15,157 @@ import (
 type RotatingFileWriter struct {
 	mu sync.Mutex
 
-	dir         string
-	baseName    string
-	ext         string
-	rotation    time.Duration
-	maxAge      time.Duration
-	maxSize     int64
-	linkName    string
+	dir           string
+	baseName      string
+	ext           string
+	rotation      time.Duration
+	layout        string
+	loc           *time.Location
+	maxAge        time.Duration
+	maxSize       int64
+	maxBackups    int
+	maxTotal      int64
+	linkName      string
+	linkMode      LinkMode
+	linkAbsolute  bool
+	compressor    Compressor
+	archiveDir    string
+	archiveLayout string
+	clock         Clock
 
-	file       *os.File
-	fileSize   int64
-	openedAt   time.Time
-	cleanupCh  chan struct{}
-	closed     bool
+	policy       FailurePolicy
+	fallback     io.Writer
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	backoff      time.Duration
+	health       WriterHealth
+	fileMode     os.FileMode
+	dirMode      os.FileMode
+	exactDirMode bool
+	owner        *FileOwner
+	keys         KeyProvider
+	sealer       *chunkSealer
+	bufSize      int
+	buf          []byte
+	syncPolicy   SyncPolicy
+
+	// lockFile is only set in MultiProcess mode; dirMu pairs with it, see
+	// lockDir.
+	lockFile *os.File
+	dirMu    sync.Mutex
+
+	file      *os.File
+	path      string
+	fileSize  int64
+	openedAt  time.Time
+	seq       int
+	cleanupCh chan struct{}
+	loopDone  chan struct{}
+	closed    bool
+
+	// finished files waiting for the background loop; guarded by pendingMu
+	// rather than mu so the write path never waits on compression.
+	pendingMu sync.Mutex
+	pending   []rotation
+	wake      chan struct{}
+
+	// frame, unframe, seal and trimTail are set by AuditWriter and run under
+	// mu: frame wraps each write and unframe takes back the last frame when
+	// it could not be written, seal returns a record to append to a file
+	// before it is closed, and trimTail returns the length of a file without
+	// a torn last record, and whether it had one.
+	frame    func(p []byte) []byte
+	unframe  func()
+	seal     func() []byte
+	trimTail func(path string) (int64, bool)
+
+	onRotate func(oldPath, newPath string)
+	onRemove func(path string)
+}
+
+// rotation records a finished file and the file the writer moved on to.
+type rotation struct {
+	oldPath string
+	newPath string
 }
 
 type RotatingFileConfig struct {
-	Dir         string
-	BaseName    string
-	Ext         string
-	Rotation    time.Duration
-	MaxAge      time.Duration
-	MaxSize     int64
-	LinkName    string
+	Dir      string
+	BaseName string
+	Ext      string
+	Rotation time.Duration
+	// TimeLayout is the Go time layout of the period stamp in file names,
+	// "200601021504" by default. It must tell consecutive periods apart.
+	TimeLayout string
+	// Location is the time zone whose wall clock defines periods and file
+	// names. Defaults to time.Local.
+	Location *time.Location
+	MaxAge   time.Duration
+	MaxSize  int64
+	// MaxBackups keeps at most this many rotated files besides the current
+	// one. Zero keeps every file younger than MaxAge.
+	MaxBackups int
+	// MaxTotalSize caps the bytes used by the current file plus all rotated
+	// files of BaseName; the oldest files are removed first. Zero disables it.
+	MaxTotalSize int64
+	// LinkName, if set, names a link in Dir that always reaches the current
+	// file; LinkMode picks a symlink, a hard link or writing to LinkName
+	// directly. Symlinks hold the target relative to the link unless
+	// LinkAbsolute is set.
+	LinkName     string
+	LinkMode     LinkMode
+	LinkAbsolute bool
+	// Compress, when set, compresses each finished file in the background.
+	Compress Compressor
+	// ArchiveDir, when set, receives finished files after any compression,
+	// keeping Dir small for tailing tools. ArchiveLayout optionally lays them
+	// out by period with a time layout such as "2006/01/02". Retention covers
+	// the whole archive tree.
+	ArchiveDir    string
+	ArchiveLayout string
+	// OnRotate is called once a finished file is complete on disk, after any
+	// compression; oldPath is its final path and newPath the file the writer
+	// continued in, empty if opening it failed. OnRemove is called after
+	// retention deletes a file. Both run on the writer's background
+	// goroutine, never on the write path, so a slow callback delays later
+	// callbacks and cleanup but not logging.
+	OnRotate func(oldPath, newPath string)
+	OnRemove func(path string)
+	// Clock drives rotation and cleanup; nil means SystemClock.
+	Clock Clock
+	// MultiProcess coordinates several processes writing the same files
+	// through flock: rotation, link updates and cleanup are serialised, and
+	// no process compresses or deletes a file another still has open. Only
+	// supported where flock(2) is; NewRotatingFileWriter fails elsewhere.
+	MultiProcess bool
+	// FailurePolicy decides what Write does while the file cannot be opened
+	// or written. Fallback receives the data under FailFallback and defaults
+	// to os.Stderr. Reopening is retried with exponential backoff between
+	// RetryBackoff and MaxRetryBackoff, one second and one minute by default.
+	FailurePolicy   FailurePolicy
+	Fallback        io.Writer
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// BufferSize enables an in-memory buffer of that many bytes in front of
+	// the file. It is flushed every FlushInterval (one second by default),
+	// on rotation, Flush, Sync and Close, and before Fatal exits.
+	BufferSize    int
+	FlushInterval time.Duration
+	// SyncPolicy decides when the file is fsynced; SyncInterval is the
+	// period for SyncPeriodic, one second by default.
+	SyncPolicy   SyncPolicy
+	SyncInterval time.Duration
+	// Encrypt, when set, encrypts files with AES-GCM under keys from the
+	// provider; read them back with OpenEncryptedLog. Compressing encrypted
+	// files saves little. Encrypt cannot be combined with MultiProcess.
+	Encrypt KeyProvider
+	// FileMode is applied exactly to new log files and compressed archives;
+	// when zero they are created 0666 minus the umask. DirMode is used for
+	// the log directory if it has to be created, 0755 by default. Owner, if
+	// set, is given to the files, the archives and a created log directory.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	Owner    *FileOwner
 }
 
 func NewRotatingFileWriter(cfg RotatingFileConfig) (*RotatingFileWriter, error) {
@@ -44,38 +175,238 @@ func NewRotatingFileWriter(cfg RotatingFileConfig) (*RotatingFileWriter, error)
 	if cfg.Rotation <= 0 {
 		cfg.Rotation = time.Hour
 	}
+	if cfg.TimeLayout == "" {
+		cfg.TimeLayout = rotatedTimeLayout
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
 	if cfg.MaxAge <= 0 {
 		cfg.MaxAge = time.Hour * 24 * 7
 	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock
+	}
+	if cfg.BufferSize > 0 && cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.SyncPolicy == SyncPeriodic && cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = defaultSyncInterval
+	}
+	if cfg.Fallback == nil {
+		cfg.Fallback = os.Stderr
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.MaxRetryBackoff < cfg.RetryBackoff {
+		cfg.MaxRetryBackoff = defaultMaxRetryBackoff
+		if cfg.MaxRetryBackoff < cfg.RetryBackoff {
+			cfg.MaxRetryBackoff = cfg.RetryBackoff
+		}
+	}
 	w := &RotatingFileWriter{
-		dir:       cfg.Dir,
-		baseName:  cfg.BaseName,
-		ext:       cfg.Ext,
-		rotation:  cfg.Rotation,
-		maxAge:    cfg.MaxAge,
-		maxSize:   cfg.MaxSize,
-		linkName:  cfg.LinkName,
-		cleanupCh: make(chan struct{}),
-	}
-	if err := os.MkdirAll(w.dir, 0755); err != nil {
-		return nil, fmt.Errorf("create log directory %s: %w", w.dir, err)
-	}
-	if err := w.openFile(time.Now()); err != nil {
+		dir:           cfg.Dir,
+		baseName:      cfg.BaseName,
+		ext:           cfg.Ext,
+		rotation:      cfg.Rotation,
+		layout:        cfg.TimeLayout,
+		loc:           cfg.Location,
+		maxAge:        cfg.MaxAge,
+		maxSize:       cfg.MaxSize,
+		maxBackups:    cfg.MaxBackups,
+		maxTotal:      cfg.MaxTotalSize,
+		linkName:      cfg.LinkName,
+		linkMode:      cfg.LinkMode,
+		linkAbsolute:  cfg.LinkAbsolute,
+		archiveDir:    cfg.ArchiveDir,
+		archiveLayout: cfg.ArchiveLayout,
+		compressor:    cfg.Compress,
+		onRotate:      cfg.OnRotate,
+		onRemove:      cfg.OnRemove,
+		clock:         cfg.Clock,
+		policy:        cfg.FailurePolicy,
+		fallback:      cfg.Fallback,
+		minBackoff:    cfg.RetryBackoff,
+		maxBackoff:    cfg.MaxRetryBackoff,
+		health:        WriterHealth{Healthy: true},
+		bufSize:       cfg.BufferSize,
+		syncPolicy:    cfg.SyncPolicy,
+		fileMode:      cfg.FileMode,
+		dirMode:       cfg.DirMode,
+		exactDirMode:  cfg.DirMode != 0,
+		keys:          cfg.Encrypt,
+		owner:         cfg.Owner,
+		cleanupCh:     make(chan struct{}),
+		loopDone:      make(chan struct{}),
+		wake:          make(chan struct{}, 1),
+	}
+	if err := w.checkLayout(); err != nil {
+		return nil, err
+	}
+	if w.dirMode == 0 {
+		w.dirMode = defaultDirMode
+	}
+	if err := w.makeDir(w.dir); err != nil {
 		return nil, err
 	}
+	if cfg.LinkMode == LinkCurrentFile && cfg.LinkName == "" {
+		return nil, errors.New("LinkCurrentFile needs a LinkName")
+	}
+	if cfg.MultiProcess {
+		if w.linkMode == LinkCurrentFile {
+			return nil, errCurrentFileMultiProcess
+		}
+		if w.keys != nil {
+			return nil, errEncryptMultiProcess
+		}
+		if err := w.openLockFile(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.openFile(w.clock.Now()); err != nil {
+		if w.lockFile != nil {
+			w.lockFile.Close()
+		}
+		return nil, err
+	}
+	w.recoverFinished()
 	go w.cleanupLoop()
+	flushEvery := cfg.FlushInterval
+	if w.bufSize == 0 || w.syncPolicy == SyncEveryWrite {
+		flushEvery = 0
+	} else {
+		w.buf = make([]byte, 0, w.bufSize)
+		registerBufferedWriter(w)
+	}
+	syncEvery := time.Duration(0)
+	if w.syncPolicy == SyncPeriodic {
+		syncEvery = cfg.SyncInterval
+	}
+	if flushEvery > 0 || syncEvery > 0 {
+		go w.flushLoop(flushEvery, syncEvery)
+	}
 	return w, nil
 }
 
-func (w *RotatingFileWriter) rotatedFileName(t time.Time) string {
-	ts := t.Format("200601021504")
-	name := w.baseName + "." + ts + w.ext
-	return filepath.Join(w.dir, name)
+// periodStart returns the start of the period containing t on the wall
+// clock in w.loc. Periods shorter than a day are counted from local
+// midnight, so DST changes shift them with the clock; longer periods are
+// whole days counted from 1970-01-01.
+func (w *RotatingFileWriter) periodStart(t time.Time) time.Time {
+	t = t.In(w.loc)
+	y, m, d := t.Date()
+	const day = 24 * time.Hour
+	if w.rotation >= day {
+		n := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+		days := int64(w.rotation / day)
+		offset := n % days
+		if offset < 0 {
+			offset += days
+		}
+		return time.Date(y, m, d-int(offset), 0, 0, 0, 0, w.loc)
+	}
+	hh, mm, ss := t.Clock()
+	elapsed := time.Duration(hh)*time.Hour + time.Duration(mm)*time.Minute +
+		time.Duration(ss)*time.Second + time.Duration(t.Nanosecond())
+	elapsed -= elapsed % w.rotation
+	return time.Date(y, m, d, int(elapsed/time.Hour), int(elapsed%time.Hour/time.Minute),
+		int(elapsed%time.Minute/time.Second), int(elapsed%time.Second), w.loc)
+}
+
+// checkLayout rejects layouts that cannot name files: ones containing a path
+// separator, ones too coarse to tell consecutive periods apart, and ones
+// that do not parse back to the period they were formatted from.
+func (w *RotatingFileWriter) checkLayout() error {
+	if strings.ContainsAny(w.layout, `/\`) {
+		return fmt.Errorf("rotated file layout %q contains a path separator", w.layout)
+	}
+	period := w.periodStart(time.Date(2006, 1, 2, 15, 4, 5, 0, w.loc))
+	next := w.periodStart(period.Add(w.rotation + w.rotation/2))
+	stamp := period.Format(w.layout)
+	if stamp == next.Format(w.layout) {
+		return fmt.Errorf("rotated file layout %q is too coarse for rotation %s", w.layout, w.rotation)
+	}
+	parsed, err := time.ParseInLocation(w.layout, stamp, w.loc)
+	if err != nil || !parsed.Equal(period) {
+		return fmt.Errorf("rotated file layout %q does not round-trip", w.layout)
+	}
+	return nil
+}
+
+// rotatedFileName names the file for a period; size rotations within the
+// period add a sequence number: app.202610161500.log, app.202610161500.1.log.
+func (w *RotatingFileWriter) rotatedFileName(period time.Time, seq int) string {
+	name := w.baseName + "." + period.In(w.loc).Format(w.layout)
+	if seq > 0 {
+		name += "." + strconv.Itoa(seq)
+	}
+	return filepath.Join(w.dir, name+w.ext)
+}
+
+// resumeSeq picks the sequence number to write to for period, continuing
+// the numbering left on disk by an earlier process. The highest existing
+// file is reused while it is uncompressed and below MaxSize.
+func (w *RotatingFileWriter) resumeSeq(period time.Time) int {
+	seq := -1
+	reusable := false
+	for _, rf := range w.listRotated() {
+		if !rf.time.Equal(period) || rf.partial || rf.seq < seq {
+			continue
+		}
+		if rf.seq > seq {
+			reusable = true
+		}
+		seq = rf.seq
+		if rf.compressed || rf.archived || (w.maxSize > 0 && rf.size >= w.maxSize) {
+			reusable = false
+		}
+	}
+	if seq < 0 {
+		return 0
+	}
+	if reusable {
+		return seq
+	}
+	return seq + 1
 }
 
 func (w *RotatingFileWriter) openFile(t time.Time) error {
-	path := w.rotatedFileName(t)
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	unlock := w.lockDir()
+	defer unlock()
+	period := w.periodStart(t)
+	if w.linkName != "" && w.linkMode == LinkCurrentFile {
+		if err := w.archiveStale(period); err != nil {
+			return err
+		}
+	}
+	return w.openPeriod(period, w.resumeSeq(period))
+}
+
+// openPeriod opens the file for period and seq; callers hold lockDir. In
+// MultiProcess mode a file claimed by another process is skipped in favour
+// of the next sequence number.
+func (w *RotatingFileWriter) openPeriod(period time.Time, seq int) error {
+	var (
+		path string
+		f    *os.File
+		err  error
+	)
+	for {
+		path = w.rotatedFileName(period, seq)
+		if w.linkMode == LinkCurrentFile {
+			path = w.linkPath()
+		}
+		if w.lockFile == nil {
+			f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, w.createMode())
+		} else {
+			f, err = openShared(path, w.createMode())
+		}
+		if err != errFileBusy {
+			break
+		}
+		seq++
+	}
 	if err != nil {
 		return fmt.Errorf("open log file %s: %w", path, err)
 	}
@@ -84,34 +415,229 @@ func (w *RotatingFileWriter) openFile(t time.Time) error {
 		f.Close()
 		return fmt.Errorf("stat log file %s: %w", path, err)
 	}
+	if err := w.applyFilePerm(f, stat); err != nil {
+		f.Close()
+		return err
+	}
+	size := stat.Size()
+	if w.trimTail != nil {
+		if end, torn := w.trimTail(path); torn {
+			if err := f.Truncate(end); err != nil {
+				f.Close()
+				return fmt.Errorf("truncate torn log file %s: %w", path, err)
+			}
+			size = end
+		}
+	}
 	w.file = f
-	w.fileSize = stat.Size()
-	w.openedAt = t.Truncate(w.rotation)
+	w.sealer = nil
+	w.path = path
+	w.fileSize = size
+	w.openedAt = period
+	w.seq = seq
 
-	if w.linkName != "" {
-		linkPath := filepath.Join(w.dir, w.linkName)
-		_ = os.Remove(linkPath)
-		_ = os.Symlink(path, linkPath)
+	if w.linkName != "" && w.linkMode != LinkCurrentFile {
+		// The link is a convenience; a failure must not stop logging.
+		_ = w.updateLink(path)
 	}
 	return nil
 }
 
 func (w *RotatingFileWriter) rotateIfNeeded(now time.Time) error {
-	needRotate := false
-	currentPeriod := now.Truncate(w.rotation)
-	if !currentPeriod.Equal(w.openedAt) {
-		needRotate = true
-	}
-	if w.maxSize > 0 && w.fileSize >= w.maxSize {
-		needRotate = true
+	period := w.periodStart(now)
+	newPeriod := !period.Equal(w.openedAt)
+	if !newPeriod && w.maxSize > 0 && w.lockFile != nil {
+		// Other processes append to the same file, so our own count is low.
+		if info, err := w.file.Stat(); err == nil {
+			w.fileSize = info.Size() + int64(len(w.buf))
+		}
 	}
-	if !needRotate {
+	full := w.maxSize > 0 && w.fileSize >= w.maxSize
+	if !newPeriod && !full {
 		return nil
 	}
-	if w.file != nil {
-		_ = w.file.Close()
+	unlock := w.lockDir()
+	defer unlock()
+	seq := w.seq + 1
+	if newPeriod || w.lockFile != nil {
+		// Join whatever file other processes have moved on to.
+		seq = w.resumeSeq(period)
 	}
-	return w.openFile(now)
+	return w.switchTo(period, seq)
+}
+
+// switchTo closes the current file, opens the one for period and seq and
+// hands the old file to the background loop. Callers hold lockDir.
+func (w *RotatingFileWriter) switchTo(period time.Time, seq int) error {
+	oldPath := w.path
+	w.sealFile()
+	_ = w.closeFile()
+	if oldPath != "" && w.linkMode == LinkCurrentFile {
+		archived, err := w.archiveCurrent(w.openedAt)
+		if err != nil {
+			w.path = ""
+			return err
+		}
+		oldPath = archived
+	}
+	if err := w.openPeriod(period, seq); err != nil {
+		// The old file is complete either way.
+		if oldPath != "" {
+			w.finish(oldPath, "")
+		}
+		w.path = ""
+		return err
+	}
+	if oldPath != "" && oldPath != w.path {
+		w.finish(oldPath, w.path)
+	}
+	return nil
+}
+
+// Rotate closes the current file and continues in a new one right away,
+// without waiting for the period to end or MaxSize to be reached.
+func (w *RotatingFileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	now := w.clock.Now()
+	unlock := w.lockDir()
+	defer unlock()
+	period := w.periodStart(now)
+	seq := w.seq + 1
+	if !period.Equal(w.openedAt) {
+		seq = w.resumeSeq(period)
+	} else if w.lockFile != nil {
+		// Other processes may have moved on, and finished the files after
+		// ours; reusing those numbers would overwrite their archives.
+		if resumed := w.resumeSeq(period); resumed > seq {
+			seq = resumed
+		}
+	}
+	if err := w.switchTo(period, seq); err != nil {
+		w.fail(now, err)
+		return err
+	}
+	w.health.Healthy = true
+	return nil
+}
+
+// Reopen closes and reopens the current path. Call it after an external
+// tool such as logrotate has moved or truncated the file; the moved file is
+// left alone and not treated as rotated.
+func (w *RotatingFileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	unlock := w.lockDir()
+	defer unlock()
+	_ = w.closeFile()
+	seq := w.seq
+	if w.lockFile != nil {
+		// Join the file other processes have moved on to.
+		seq = w.resumeSeq(w.openedAt)
+	}
+	if err := w.openPeriod(w.openedAt, seq); err != nil {
+		w.fail(w.clock.Now(), err)
+		return err
+	}
+	w.health.Healthy = true
+	return nil
+}
+
+// finish hands a closed file to the background loop, which compresses it
+// when configured and then applies retention.
+func (w *RotatingFileWriter) finish(oldPath, newPath string) {
+	w.pendingMu.Lock()
+	w.pending = append(w.pending, rotation{oldPath: oldPath, newPath: newPath})
+	w.pendingMu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *RotatingFileWriter) processPending() {
+	w.pendingMu.Lock()
+	rotations := w.pending
+	w.pending = nil
+	w.pendingMu.Unlock()
+	var busy []rotation
+	for _, r := range rotations {
+		// In MultiProcess mode, whichever process claims the file first
+		// finishes it; the others see it busy or already gone.
+		claimed, err := w.claim(r.oldPath)
+		if err == errFileBusy {
+			busy = append(busy, r)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		path := r.oldPath
+		if w.compressor != nil && !w.isCompressed(path) {
+			compressed, err := w.compressFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to compress log file: %v\n", err)
+			}
+			if compressed != "" {
+				path = compressed
+			}
+		}
+		if w.archiveDir != "" {
+			archived, err := w.archive(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to archive log file: %v\n", err)
+			} else {
+				path = archived
+			}
+		}
+		release(claimed)
+		if w.onRotate != nil {
+			runCallback(func() { w.onRotate(path, r.newPath) })
+		}
+	}
+	if len(busy) > 0 {
+		// Retried on the next rotation or cleanup tick.
+		w.pendingMu.Lock()
+		w.pending = append(busy, w.pending...)
+		w.pendingMu.Unlock()
+	}
+}
+
+func (w *RotatingFileWriter) isCompressed(path string) bool {
+	rf, ok := w.parseRotatedName(filepath.Base(path))
+	return ok && rf.compressed
+}
+
+// runCallback shields the background loop from a panicking user callback.
+func runCallback(fn func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: Logrus rotation callback error:", err)
+		}
+	}()
+	fn()
+}
+
+// remove deletes a file of this writer and reports whether it did. Callers
+// hold lockDir.
+func (w *RotatingFileWriter) remove(path string) bool {
+	claimed, err := w.claim(path)
+	if err != nil {
+		return false
+	}
+	err = os.Remove(path)
+	release(claimed)
+	if err != nil {
+		return false
+	}
+	w.pruneArchiveDirs(path)
+	return true
 }
 
 func (w *RotatingFileWriter) Write(p []byte) (int, error) {
@@ -121,66 +647,183 @@ func (w *RotatingFileWriter) Write(p []byte) (int, error) {
 	if w.closed {
 		return 0, os.ErrClosed
 	}
-	if err := w.rotateIfNeeded(time.Now()); err != nil {
-		return 0, err
+	now := w.clock.Now()
+	if err := w.ensureFile(now); err != nil {
+		return w.writeFailed(p, err)
+	}
+	data := p
+	if w.frame != nil {
+		data = w.frame(p)
+	}
+	n, err := w.writeOut(data)
+	w.fileSize += int64(n)
+	if err != nil && w.unframe != nil {
+		// A record reaches the file whole or not at all, see writeFile.
+		w.unframe()
+	}
+	if err == nil && w.syncPolicy == SyncEveryWrite {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		w.fail(now, err)
+		if _, err := w.writeFailed(data[n:], err); err != nil {
+			if w.frame != nil && n > 0 {
+				// n counts framed bytes.
+				n = len(p)
+			}
+			return n, err
+		}
+		return len(p), nil
+	}
+	return len(p), nil
+}
+
+// sealFile appends the seal record to the open file. Callers hold w.mu.
+func (w *RotatingFileWriter) sealFile() {
+	if w.seal == nil || w.file == nil {
+		return
 	}
-	n, err := w.file.Write(p)
+	data := w.seal()
+	n, err := w.writeOut(data)
 	w.fileSize += int64(n)
-	return n, err
+	if err != nil {
+		w.unframe()
+		fmt.Fprintf(os.Stderr, "Failed to seal log file: %v\n", err)
+	}
 }
 
+// Close closes the current file and waits for pending background work, such
+// as compression of already rotated files, to finish.
 func (w *RotatingFileWriter) Close() error {
 	w.mu.Lock()
-	defer w.mu.Unlock()
 	if w.closed {
+		w.mu.Unlock()
 		return nil
 	}
 	w.closed = true
 	close(w.cleanupCh)
-	if w.file != nil {
-		return w.file.Close()
+	w.sealFile()
+	err := w.closeFile()
+	if len(w.buf) > 0 {
+		// Data that never reached a file after a failure.
+		if _, ferr := w.writeFailed(w.buf, w.health.LastError); err == nil {
+			err = ferr
+		}
+		w.buf = nil
 	}
-	return nil
+	w.mu.Unlock()
+	unregisterBufferedWriter(w)
+	<-w.loopDone
+	if w.lockFile != nil {
+		w.lockFile.Close()
+	}
+	return err
 }
 
 func (w *RotatingFileWriter) cleanupLoop() {
-	ticker := time.NewTicker(w.maxAge / 2)
-	if ticker.C == nil {
-		return
-	}
-	defer ticker.Stop()
+	defer close(w.loopDone)
+	ticks, stop := w.clock.NewTicker(w.maxAge / 2)
+	defer stop()
 	for {
 		select {
 		case <-w.cleanupCh:
+			w.processPending()
+			w.removeOldFiles(w.clock.Now().Add(-w.maxAge))
 			return
-		case now := <-ticker.C:
+		case <-w.wake:
+			w.processPending()
+			w.removeOldFiles(w.clock.Now().Add(-w.maxAge))
+		case now := <-ticks:
+			w.processPending()
 			w.removeOldFiles(now.Add(-w.maxAge))
 		}
 	}
 }
 
+// removeOldFiles deletes rotated files whose period started before cutoff,
+// then trims the remaining ones, newest first, to MaxBackups and
+// MaxTotalSize. Age comes from the file name, not the modification time.
+// OnRemove is called once lockDir is released: a Write that rotates holds
+// w.mu and waits for lockDir, so a callback that logs to this writer under
+// the lock would deadlock, and in MultiProcess mode a slow one would stall
+// rotation in every process.
 func (w *RotatingFileWriter) removeOldFiles(cutoff time.Time) {
-	entries, err := os.ReadDir(w.dir)
-	if err != nil {
-		return
+	removed := w.expire(cutoff)
+	if w.onRemove != nil {
+		for _, path := range removed {
+			runCallback(func() { w.onRemove(path) })
+		}
+	}
+}
+
+// expire does the work of removeOldFiles under lockDir and returns the
+// removed files.
+func (w *RotatingFileWriter) expire(cutoff time.Time) (removed []string) {
+	current := w.currentPath()
+	unlock := w.lockDir()
+	defer unlock()
+	var total int64
+	backups := 0
+	if w.linkMode == LinkCurrentFile && current != "" {
+		// The current file is not among the timestamped ones.
+		if info, err := os.Stat(current); err == nil {
+			total = info.Size()
+		}
 	}
-	prefix := w.baseName + "."
-	for _, entry := range entries {
-		if entry.IsDir() {
+	for _, rf := range w.listRotated() {
+		if rf.path == current {
+			total += rf.size
 			continue
 		}
-		name := entry.Name()
-		if len(name) < len(prefix) || name[:len(prefix)] != prefix {
+		if rf.partial {
+			if rf.time.Before(cutoff) {
+				_ = os.Remove(rf.path)
+			}
 			continue
 		}
-		info, err := entry.Info()
-		if err != nil {
+		if rf.time.Before(cutoff) {
+			if w.remove(rf.path) {
+				removed = append(removed, rf.path)
+			}
 			continue
 		}
-		if info.ModTime().Before(cutoff) {
-			_ = os.Remove(filepath.Join(w.dir, name))
+		backups++
+		total += rf.size
+		if (w.maxBackups > 0 && backups > w.maxBackups) || (w.maxTotal > 0 && total > w.maxTotal) {
+			if w.remove(rf.path) {
+				removed = append(removed, rf.path)
+			}
 		}
 	}
+	return removed
+}
+
+// recoverFinished queues files left behind by a previous process: finished
+// files that were never compressed, and partial compressions to discard.
+func (w *RotatingFileWriter) recoverFinished() {
+	if w.compressor == nil && w.archiveDir == "" {
+		return
+	}
+	current := w.currentPath()
+	for _, rf := range w.listRotated() {
+		switch {
+		case rf.path == current:
+		case rf.partial:
+			// Another process may be writing it right now.
+			if w.lockFile == nil {
+				_ = os.Remove(rf.path)
+			}
+		case !rf.compressed && w.compressor != nil,
+			w.archiveDir != "" && !rf.archived:
+			w.finish(rf.path, current)
+		}
+	}
+}
+
+func (w *RotatingFileWriter) currentPath() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.path
 }
 
 var _ io.WriteCloser = (*RotatingFileWriter)(nil)
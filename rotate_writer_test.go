@@ -0,0 +1,575 @@
+package logrus
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnulwh/logrus/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ Clock = (*clocktest.FakeClock)(nil)
+
+// testNow is a fixed time in the middle of an hour, so tests that write
+// several times are not split by a period boundary.
+var testNow = time.Date(2026, 10, 16, 15, 30, 0, 0, time.Local)
+
+// forceRotate drives a rotation at the given time without waiting on the
+// wall clock.
+func forceRotate(t *testing.T, w *RotatingFileWriter, now time.Time) {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	require.NoError(t, w.rotateIfNeeded(now))
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestRotatingFileWriterCompressesRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		Compress: &GzipCompressor{},
+	})
+	require.NoError(t, err)
+
+	first := w.currentPath()
+	_, err = w.Write([]byte("first period\n"))
+	require.NoError(t, err)
+
+	forceRotate(t, w, time.Now().Add(2*time.Hour))
+	require.NoError(t, w.Close())
+
+	_, err = os.Stat(first)
+	assert.True(t, os.IsNotExist(err), "the original should be removed once compressed")
+	assert.Equal(t, "first period\n", readGzip(t, first+".gz"))
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestRotatingFileWriterCompressionKeepsExistingArchive(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Compress: &GzipCompressor{}})
+	require.NoError(t, err)
+	defer w.Close()
+	path := seedRotated(t, dir, 1)[0]
+	require.NoError(t, os.WriteFile(path+".gz", []byte("archived"), 0644))
+
+	_, err = w.compressFile(path)
+	assert.Error(t, err)
+	b, err := os.ReadFile(path + ".gz")
+	require.NoError(t, err)
+	assert.Equal(t, "archived", string(b))
+	assert.True(t, exists(path))
+	assert.False(t, exists(path+".gz.tmp"))
+}
+
+func TestRotatingFileWriterRemovesCompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		Compress: &GzipCompressor{},
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	old := filepath.Join(dir, "app.200001010000.log.gz")
+	require.NoError(t, os.WriteFile(old, []byte("x"), 0644))
+
+	w.removeOldFiles(time.Now().Add(-w.maxAge))
+
+	_, err = os.Stat(old)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(w.currentPath())
+	assert.NoError(t, err, "the current file must never be removed")
+}
+
+// seedRotated creates rotated files of the given sizes, newest first, one
+// hour apart.
+func seedRotated(t *testing.T, dir string, sizes ...int) []string {
+	t.Helper()
+	var paths []string
+	now := time.Now()
+	for i, size := range sizes {
+		ts := now.Add(-time.Duration(i+1) * time.Hour)
+		path := filepath.Join(dir, "app."+ts.Format("200601021504")+".log")
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestRotatingFileWriterMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	paths := seedRotated(t, dir, 1, 1, 1, 1)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", MaxBackups: 2})
+	require.NoError(t, err)
+	defer w.Close()
+
+	w.removeOldFiles(time.Now().Add(-w.maxAge))
+
+	assert.True(t, exists(paths[0]))
+	assert.True(t, exists(paths[1]))
+	assert.False(t, exists(paths[2]))
+	assert.False(t, exists(paths[3]))
+	assert.True(t, exists(w.currentPath()))
+}
+
+func TestRotatingFileWriterMaxTotalSize(t *testing.T) {
+	dir := t.TempDir()
+	paths := seedRotated(t, dir, 40, 40, 40)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", MaxTotalSize: 100})
+	require.NoError(t, err)
+	defer w.Close()
+	_, err = w.Write(make([]byte, 10))
+	require.NoError(t, err)
+
+	w.removeOldFiles(time.Now().Add(-w.maxAge))
+
+	assert.True(t, exists(paths[0]))
+	assert.True(t, exists(paths[1]))
+	assert.False(t, exists(paths[2]), "10 + 40 + 40 + 40 exceeds the 100 byte quota")
+}
+
+func TestRotatingFileWriterAppliesRetentionAfterRotation(t *testing.T) {
+	dir := t.TempDir()
+	paths := seedRotated(t, dir, 1, 1)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", MaxBackups: 1})
+	require.NoError(t, err)
+	first := w.currentPath()
+
+	forceRotate(t, w, time.Now().Add(2*time.Hour))
+	require.NoError(t, w.Close())
+
+	assert.True(t, exists(first), "the file just rotated out is the newest backup")
+	assert.False(t, exists(paths[0]))
+	assert.False(t, exists(paths[1]))
+}
+
+func TestRotatingFileWriterCleanupSharedDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+	dir := t.TempDir()
+	var writers []*RotatingFileWriter
+	for _, base := range []string{"app", "app.debug", "app.info"} {
+		w, err := NewRotatingFileWriter(RotatingFileConfig{
+			Dir:      dir,
+			BaseName: base,
+			LinkName: base + ".log",
+			MaxAge:   time.Hour,
+		})
+		require.NoError(t, err)
+		writers = append(writers, w)
+	}
+	defer func() {
+		for _, w := range writers {
+			w.Close()
+		}
+	}()
+
+	oldCommon := filepath.Join(dir, "app.200001010000.log")
+	oldDebug := filepath.Join(dir, "app.debug.200001010000.log")
+	unrelated := filepath.Join(dir, "app.notes.txt")
+	for _, path := range []string{oldCommon, oldDebug, unrelated} {
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+	}
+
+	writers[0].removeOldFiles(time.Now().Add(-time.Hour))
+
+	assert.False(t, exists(oldCommon))
+	assert.True(t, exists(oldDebug), "app must not clean up app.debug files")
+	assert.True(t, exists(unrelated))
+	for _, w := range writers {
+		assert.True(t, exists(w.currentPath()))
+		assert.True(t, exists(filepath.Join(dir, w.linkName)), w.linkName)
+	}
+
+	writers[1].removeOldFiles(time.Now().Add(-time.Hour))
+	assert.False(t, exists(oldDebug))
+}
+
+func TestRotatingFileWriterAgeFromFileName(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", MaxAge: time.Hour})
+	require.NoError(t, err)
+	defer w.Close()
+
+	// A freshly touched file from an old period still ages out, and an old
+	// mtime on a recent period does not.
+	oldName := filepath.Join(dir, "app.200001010000.log")
+	require.NoError(t, os.WriteFile(oldName, []byte("x"), 0644))
+	recent := filepath.Join(dir, "app."+time.Now().Add(-30*time.Minute).Format(rotatedTimeLayout)+".log")
+	require.NoError(t, os.WriteFile(recent, []byte("x"), 0644))
+	stale := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(recent, stale, stale))
+
+	w.removeOldFiles(time.Now().Add(-time.Hour))
+
+	assert.False(t, exists(oldName))
+	assert.True(t, exists(recent))
+}
+
+func TestRotatingFileWriterRecoversInterruptedCompression(t *testing.T) {
+	dir := t.TempDir()
+	stamp := time.Now().Add(-2 * time.Hour).Format(rotatedTimeLayout)
+	finished := filepath.Join(dir, "app."+stamp+".log")
+	require.NoError(t, os.WriteFile(finished, []byte("left behind\n"), 0644))
+	require.NoError(t, os.WriteFile(finished+".gz.tmp", []byte("garbage"), 0644))
+
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Compress: &GzipCompressor{}})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.False(t, exists(finished))
+	assert.False(t, exists(finished+".gz.tmp"))
+	assert.Equal(t, "left behind\n", readGzip(t, finished+".gz"))
+}
+
+func TestRotatingFileWriterSizeRotationUsesSequence(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		MaxSize:  10,
+		Clock:    clocktest.NewFakeClock(testNow),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte("0123456789"))
+		require.NoError(t, err)
+	}
+	period := w.openedAt
+	require.NoError(t, w.Close())
+
+	for seq := 0; seq < 3; seq++ {
+		b, err := os.ReadFile(w.rotatedFileName(period, seq))
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(b), "seq %d", seq)
+	}
+	assert.Equal(t, filepath.Join(dir, "app."+period.Format(rotatedTimeLayout)+".2.log"), w.rotatedFileName(period, 2))
+}
+
+func TestRotatingFileWriterResumesSequenceAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := RotatingFileConfig{Dir: dir, BaseName: "app", MaxSize: 10, Clock: clocktest.NewFakeClock(testNow)}
+	w, err := NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, 1, w.seq)
+
+	// The partly filled .1 file is reused, then numbering continues at .2.
+	w, err = NewRotatingFileWriter(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, w.seq)
+	_, err = w.Write([]byte("defghij"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("next"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, w.seq)
+	require.NoError(t, w.Close())
+
+	b, err := os.ReadFile(w.rotatedFileName(w.openedAt, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefghij", string(b))
+}
+
+func TestParseRotatedName(t *testing.T) {
+	w := &RotatingFileWriter{baseName: "app", ext: ".log", layout: rotatedTimeLayout, loc: time.Local}
+	for name, want := range map[string]bool{
+		"app.202610161500.log":         true,
+		"app.202610161500.3.log":       true,
+		"app.202610161500.3.log.gz":    true,
+		"app.202610161500.log.zst":     true,
+		"app.202610161500.log.gz.tmp":  true,
+		"app.log":                      false,
+		"app.debug.202610161500.log":   false,
+		"app.202610161500.0.log":       false,
+		"app.202610161500.01.log":      false,
+		"app.20261016150.log":          false,
+		"app.202610161500.log.GZ":      false,
+		"app.202610161500.txt":         false,
+		"application.202610161500.log": false,
+	} {
+		_, ok := w.parseRotatedName(name)
+		assert.Equal(t, want, ok, name)
+	}
+}
+
+func TestRotatingFileWriterPeriodStartInLocation(t *testing.T) {
+	utc8 := time.FixedZone("UTC+8", 8*3600)
+	w := &RotatingFileWriter{rotation: 24 * time.Hour, loc: utc8}
+
+	// 07:59 local is 23:59 UTC the day before; the daily period still
+	// starts at local midnight.
+	start := w.periodStart(time.Date(2026, 10, 16, 7, 59, 0, 0, utc8))
+	assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, utc8), start)
+	start = w.periodStart(time.Date(2026, 10, 15, 16, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, utc8), start)
+
+	w.rotation = 6 * time.Hour
+	start = w.periodStart(time.Date(2026, 10, 16, 13, 30, 0, 0, utc8))
+	assert.Equal(t, time.Date(2026, 10, 16, 12, 0, 0, 0, utc8), start)
+}
+
+func TestRotatingFileWriterPeriodStartAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	w := &RotatingFileWriter{rotation: time.Hour, loc: ny}
+
+	// 2026-03-08 02:00 EST jumps to 03:00 EDT.
+	before := time.Date(2026, 3, 8, 1, 30, 0, 0, ny)
+	after := before.Add(time.Hour)
+	assert.Equal(t, 3, after.Hour())
+	assert.Equal(t, time.Date(2026, 3, 8, 1, 0, 0, 0, ny), w.periodStart(before))
+	assert.Equal(t, time.Date(2026, 3, 8, 3, 0, 0, 0, ny), w.periodStart(after))
+
+	w.rotation = 24 * time.Hour
+	assert.Equal(t, time.Date(2026, 3, 8, 0, 0, 0, 0, ny), w.periodStart(after))
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, ny),
+		w.periodStart(time.Date(2026, 3, 9, 0, 30, 0, 0, ny)))
+}
+
+func TestRotatingFileWriterTimeLayout(t *testing.T) {
+	dir := t.TempDir()
+	utc8 := time.FixedZone("UTC+8", 8*3600)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        dir,
+		BaseName:   "app",
+		Rotation:   24 * time.Hour,
+		TimeLayout: "2006.01.02",
+		Location:   utc8,
+		MaxSize:    1,
+		Clock:      clocktest.NewFakeClock(time.Date(2026, 10, 16, 7, 59, 0, 0, utc8)),
+	})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("x"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("y"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.True(t, exists(filepath.Join(dir, "app.2026.10.16.log")))
+	assert.True(t, exists(filepath.Join(dir, "app.2026.10.16.1.log")))
+	files := w.listRotated()
+	require.Len(t, files, 2)
+	assert.Equal(t, 1, files[0].seq)
+	assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, utc8), files[1].time)
+}
+
+func TestRotatingFileWriterRejectsBadLayouts(t *testing.T) {
+	for _, layout := range []string{"2006/01/02", "20060102", "hello"} {
+		_, err := NewRotatingFileWriter(RotatingFileConfig{
+			Dir:        t.TempDir(),
+			BaseName:   "app",
+			Rotation:   time.Hour,
+			TimeLayout: layout,
+		})
+		assert.Error(t, err, layout)
+	}
+}
+
+func TestRotatingFileWriterRotate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clocktest.NewFakeClock(testNow)})
+	require.NoError(t, err)
+	first := w.currentPath()
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Rotate())
+	second := w.currentPath()
+	assert.NotEqual(t, first, second)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.ErrorIs(t, w.Rotate(), os.ErrClosed)
+
+	b, err := os.ReadFile(first)
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(b))
+	b, err = os.ReadFile(second)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(b))
+}
+
+func TestRotatingFileWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clocktest.NewFakeClock(testNow)})
+	require.NoError(t, err)
+	defer w.Close()
+	path := w.currentPath()
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	// What logrotate does in "create" mode.
+	moved := filepath.Join(dir, "moved.log")
+	require.NoError(t, os.Rename(path, moved))
+	require.NoError(t, w.Reopen())
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, path, w.currentPath())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(b))
+	b, err = os.ReadFile(moved)
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(b))
+}
+
+func TestRotatingFileWriterCallbacks(t *testing.T) {
+	dir := t.TempDir()
+	seeded := seedRotated(t, dir, 1)
+	var (
+		mu      sync.Mutex
+		rotated [][2]string
+		removed []string
+	)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        dir,
+		BaseName:   "app",
+		MaxBackups: 1,
+		Compress:   &GzipCompressor{},
+		OnRotate: func(oldPath, newPath string) {
+			mu.Lock()
+			defer mu.Unlock()
+			rotated = append(rotated, [2]string{oldPath, newPath})
+		},
+		OnRemove: func(path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			removed = append(removed, path)
+		},
+	})
+	require.NoError(t, err)
+	first := w.currentPath()
+	require.NoError(t, w.Rotate())
+	second := w.currentPath()
+	require.NoError(t, w.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][2]string{
+		{seeded[0] + ".gz", first},
+		{first + ".gz", second},
+	}, rotated)
+	assert.Equal(t, []string{seeded[0] + ".gz"}, removed)
+}
+
+func TestRotatingFileWriterOnRemoveWrites(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	var (
+		w    *RotatingFileWriter
+		once sync.Once
+		done = make(chan struct{})
+	)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:        dir,
+		BaseName:   "app",
+		Clock:      clock,
+		MaxBackups: 1,
+		OnRemove: func(path string) {
+			once.Do(func() {
+				// Log from the callback while another Write is rotating.
+				clock.Advance(time.Hour)
+				rotated := make(chan struct{})
+				go func() {
+					_, _ = w.Write([]byte("rotating\n"))
+					close(rotated)
+				}()
+				time.Sleep(20 * time.Millisecond)
+				_, _ = w.Write([]byte("removed " + filepath.Base(path) + "\n"))
+				<-rotated
+				close(done)
+			})
+		},
+	})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte("line\n"))
+		require.NoError(t, err)
+		clock.Advance(time.Hour)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnRemove deadlocked with a rotating Write")
+	}
+	require.NoError(t, w.Close())
+}
+
+func TestRotatingFileWriterHourlyRotation(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{Dir: dir, BaseName: "app", Clock: clock})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte(clock.Now().Format("15:04\n")))
+		require.NoError(t, err)
+		clock.Advance(40 * time.Minute)
+	}
+	require.NoError(t, w.Close())
+
+	for hour, want := range map[int]string{15: "15:30\n", 16: "16:10\n16:50\n"} {
+		start := time.Date(2026, 10, 16, hour, 0, 0, 0, time.Local)
+		b, err := os.ReadFile(w.rotatedFileName(start, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(b))
+	}
+}
+
+func TestRotatingFileWriterCleanupTicker(t *testing.T) {
+	dir := t.TempDir()
+	clock := clocktest.NewFakeClock(testNow)
+	w, err := NewRotatingFileWriter(RotatingFileConfig{
+		Dir:      dir,
+		BaseName: "app",
+		MaxAge:   2 * time.Hour,
+		Clock:    clock,
+	})
+	require.NoError(t, err)
+	defer w.Close()
+	old := w.rotatedFileName(testNow.Add(-90*time.Minute), 0)
+	require.NoError(t, os.WriteFile(old, []byte("x"), 0644))
+	require.Eventually(t, func() bool { return clock.Tickers() == 1 }, 5*time.Second, time.Millisecond)
+
+	clock.Advance(time.Hour)
+	assert.Eventually(t, func() bool { return !exists(old) }, 5*time.Second, time.Millisecond)
+	assert.True(t, exists(w.currentPath()))
+}
@@ -0,0 +1,168 @@
+package logrus
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// SlogHandlerOptions configures a SlogHandler.
+type SlogHandlerOptions struct {
+	// NestGroups stores the attrs of a group as a Fields value under the
+	// group's name. By default they are flattened into dotted keys, so
+	// slog.Group("req", "id", 1) becomes the field "req.id".
+	NestGroups bool
+}
+
+// SlogHandler is a slog.Handler that logs records through a Logger, so
+// log/slog callers share its level, formatter, output and hooks. Attrs become
+// fields and the caller is taken from the record.
+type SlogHandler struct {
+	logger *Logger
+	opts   SlogHandlerOptions
+	// preset holds the attrs added by WithAttrs with the groups open at the
+	// time, applied to every record before its own attrs.
+	preset []slogAttrs
+	groups []string
+}
+
+type slogAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+// NewSlogHandler returns a handler logging to logger. opts may be nil.
+func NewSlogHandler(logger *Logger, opts *SlogHandlerOptions) *SlogHandler {
+	h := &SlogHandler{logger: logger}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Slog returns a slog.Logger that logs through logger.
+func (logger *Logger) Slog() *slog.Logger {
+	return slog.New(NewSlogHandler(logger, nil))
+}
+
+// SetSlogDefault makes logger the destination of slog's default logger and
+// of the standard log package, which logs at InfoLevel. Both report their
+// own callers. restore puts the previous slog default and log output back.
+func SetSlogDefault(logger *Logger) (restore func()) {
+	prev := slog.Default()
+	out, flags := log.Writer(), log.Flags()
+	// slog only records the caller of log.Print and friends when the log
+	// package is asked for file names at the time the default is set.
+	log.SetFlags(log.Lshortfile)
+	slog.SetDefault(logger.Slog())
+	return func() {
+		slog.SetDefault(prev)
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	}
+}
+
+// slogLevel maps a slog level to the Level at or below it. Levels above
+// slog.LevelError map to ErrorLevel, so a record never panics or exits.
+func slogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TraceLevel
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	default:
+		return ErrorLevel
+	}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.IsLevelEnabled(slogLevel(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	level := slogLevel(r.Level)
+	entry := h.logger.newEntry()
+	defer h.logger.releaseEntry(entry)
+	for _, p := range h.preset {
+		for _, a := range p.attrs {
+			h.addAttr(entry.Data, p.groups, a)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(entry.Data, h.groups, a)
+		return true
+	})
+	entry.Time = r.Time
+	entry.Context = ctx
+	entry.logFrom(level, r.Message, func() *runtime.Frame {
+		if r.PC == 0 {
+			return nil
+		}
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		return &f
+	})
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.preset = append(h.preset[:len(h.preset):len(h.preset)], slogAttrs{groups: h.groups, attrs: attrs})
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// addAttr stores a under groups in data, following the slog.Handler rules:
+// empty attrs and empty groups are dropped and groups without a key are
+// inlined.
+func (h *SlogHandler) addAttr(data Fields, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range attrs {
+			h.addAttr(data, groups, ga)
+		}
+		return
+	}
+	if !h.opts.NestGroups {
+		if len(groups) > 0 {
+			a.Key = strings.Join(groups, ".") + "." + a.Key
+		}
+		data[a.Key] = a.Value.Any()
+		return
+	}
+	for _, g := range groups {
+		sub, ok := data[g].(Fields)
+		if !ok {
+			sub = make(Fields)
+			data[g] = sub
+		}
+		data = sub
+	}
+	data[a.Key] = a.Value.Any()
+}
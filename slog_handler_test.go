@@ -0,0 +1,136 @@
+package logrus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stdlog "log"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSlogTestLogger(buf *bytes.Buffer) *Logger {
+	return &Logger{
+		Out:          buf,
+		Formatter:    new(JSONFormatter),
+		Hooks:        make(LevelHooks),
+		ConsoleLevel: DebugLevel,
+		HookLevel:    DebugLevel,
+		ReportCaller: true,
+	}
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]interface{}
+		require.NoError(t, dec.Decode(&line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestSlogHandlerFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewSlogHandler(newSlogTestLogger(&buf), nil))
+	log.With("svc", "billing").WithGroup("req").With("id", 7).
+		Info("done", "status", 200, slog.Group("user", "name", "ann"), slog.Group("", "inline", true), slog.Group("empty"))
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	line := lines[0]
+	assert.Equal(t, "done", line["msg"])
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, "billing", line["svc"])
+	assert.Equal(t, float64(7), line["req.id"])
+	assert.Equal(t, float64(200), line["req.status"])
+	assert.Equal(t, "ann", line["req.user.name"])
+	assert.Equal(t, true, line["req.inline"])
+	assert.NotContains(t, line, "req.empty")
+	_, file := filepath.Split(line["file"].(string))
+	assert.Contains(t, file, "slog_handler_test.go", "the caller is the slog call site")
+	assert.Contains(t, line["func"], "TestSlogHandlerFields")
+}
+
+func TestSlogHandlerNestGroups(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewSlogHandler(newSlogTestLogger(&buf), &SlogHandlerOptions{NestGroups: true}))
+	log.WithGroup("req").With("id", 7).Info("done", slog.Group("user", "name", "ann"), "status", 200)
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, map[string]interface{}{
+		"id":     float64(7),
+		"status": float64(200),
+		"user":   map[string]interface{}{"name": "ann"},
+	}, lines[0]["req"])
+}
+
+func TestSlogHandlerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSlogTestLogger(&buf)
+	logger.SetLevel(InfoLevel)
+	h := NewSlogHandler(logger, nil)
+	log := slog.New(h)
+
+	assert.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+	assert.True(t, h.Enabled(context.Background(), slog.LevelInfo))
+	log.Debug("hidden")
+	log.Warn("warn")
+	assert.NotPanics(t, func() { log.Log(context.Background(), slog.LevelError+8, "severe") })
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "warning", lines[0]["level"])
+	assert.Equal(t, "error", lines[1]["level"])
+
+	for level, want := range map[slog.Level]Level{
+		slog.LevelDebug - 4: TraceLevel,
+		slog.LevelDebug:     DebugLevel,
+		slog.LevelInfo + 2:  InfoLevel,
+		slog.LevelWarn:      WarnLevel,
+		slog.LevelError:     ErrorLevel,
+		slog.LevelError + 4: ErrorLevel,
+	} {
+		assert.Equal(t, want, slogLevel(level), "slog level %v", level)
+	}
+}
+
+func TestLoggerSlog(t *testing.T) {
+	var buf bytes.Buffer
+	newSlogTestLogger(&buf).Slog().Error("failed", "err", "boom")
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "error", lines[0]["level"])
+	assert.Equal(t, "boom", lines[0]["err"])
+	assert.Contains(t, lines[0]["func"], "TestLoggerSlog")
+}
+
+func TestSetSlogDefault(t *testing.T) {
+	var buf bytes.Buffer
+	prevOut := stdlog.Writer()
+	restore := SetSlogDefault(newSlogTestLogger(&buf))
+	slog.Warn("from slog", "n", 1)
+	stdlog.Printf("from %s", "log")
+	restore()
+	assert.Equal(t, prevOut, stdlog.Writer())
+	_, isLogrus := slog.Default().Handler().(*SlogHandler)
+	assert.False(t, isLogrus)
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "from slog", lines[0]["msg"])
+	assert.Equal(t, "warning", lines[0]["level"])
+	assert.Equal(t, "from log", lines[1]["msg"])
+	assert.Equal(t, "info", lines[1]["level"])
+	for _, line := range lines {
+		assert.Contains(t, line["func"], "TestSetSlogDefault")
+		assert.Contains(t, line["file"], "slog_handler_test.go")
+	}
+}